@@ -4,110 +4,169 @@ import (
 	"errors"
 	"ivan/timer"
 	"ivan/tracker"
+	"log"
+	"os"
+	"time"
 
 	"github.com/hajimehoshi/ebiten"
-	"github.com/hajimehoshi/ebiten/inpututil"
 )
 
-const configPath = "assets/config.json"
+const configPollInterval = time.Second
 
 var errCloseApp = errors.New("user requested app close")
 
 type App struct {
+	opts    options
 	tracker *tracker.Tracker
 	timer   *timer.Timer
 	config  config
+	journal *journal
+
+	pendingSession   *session // previous session waiting for the user to restore it
+	previousSession  *session // session discarded by the last reset
+	lastSavedSession []byte
+	lastSavedRanking []byte
+	nextAutosave     time.Time
+
+	configModTime   time.Time
+	nextConfigCheck time.Time
+
+	events inputQueue
 }
 
-func NewApp() (*App, error) {
-	config, err := loadConfig(configPath)
+func NewApp(opts options) (*App, error) {
+	stat, err := os.Stat(opts.configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := loadConfig(opts)
 	if err != nil {
 		return nil, err
 	}
 
 	size := config.windowSize()
 	ebiten.SetWindowSize(size.X, size.Y)
-	ebiten.SetWindowPosition(1920-size.X, 0)
+	if opts.hasWindowPos {
+		ebiten.SetWindowPosition(opts.windowPos.X, opts.windowPos.Y)
+	} else {
+		ebiten.SetWindowPosition(1920-size.X, 0)
+	}
 
 	timer, err := timer.New(config.Dimensions.Timer)
 	if err != nil {
 		return nil, err
 	}
 
-	tracker, err := tracker.New(
-		config.Dimensions.ItemTracker,
-		config.Dimensions.HintTracker,
-		config.Items,
-		config.ZoneItemMap,
-		config.Locations,
-	)
+	tracker, err := tracker.New(opts.assetsDir, config.trackerConfig())
 	if err != nil {
 		return nil, err
 	}
 
-	return &App{
-		tracker: tracker,
-		timer:   timer,
-		config:  config,
-	}, nil
-}
+	journal, err := openJournal(opts.statePath(journalName), timer)
+	if err != nil {
+		return nil, err
+	}
 
-func (app *App) Update(screen *ebiten.Image) error {
-	_, wheel := ebiten.Wheel()
+	previousSession, err := loadSession(opts.statePath(previousSessionName))
+	if err != nil {
+		log.Printf("warning: unable to load previous session: %s", err)
+	}
 
-	switch {
-	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
-		if !app.timer.IsRunning() && !app.tracker.EatInput() {
-			return errCloseApp
-		}
-		app.tracker.Cancel()
-
-	case inpututil.IsKeyJustPressed(ebiten.KeyHome):
-		if !app.timer.IsRunning() {
-			config, err := loadConfig(configPath)
-			if err != nil {
-				return err
-			}
-			app.config = config
-			app.tracker.Reset(app.config.Items, app.config.ZoneItemMap)
+	ranking, err := loadRanking(opts.statePath(rankingName))
+	if err != nil {
+		log.Printf("warning: unable to load ranking: %s", err)
+	}
+	tracker.SetRanking(ranking)
+
+	app := &App{
+		opts:            opts,
+		tracker:         tracker,
+		timer:           timer,
+		config:          config,
+		journal:         journal,
+		previousSession: previousSession,
+		configModTime:   stat.ModTime(),
+	}
+
+	if opts.replayPath != "" {
+		if err := app.replay(opts.replayPath); err != nil {
+			return nil, err
 		}
+	} else {
+		app.offerSession()
+	}
+	tracker.SetJournal(journal)
+
+	return app, nil
+}
 
-	case inpututil.IsKeyJustPressed(ebiten.KeyEnter):
-		app.tracker.Submit()
+func (app *App) Update(screen *ebiten.Image) error {
+	if app.pendingSession != nil {
+		app.updateRestorePrompt()
+		return nil
+	}
+	defer app.autosave()
+	app.watchConfig()
 
-	case inpututil.IsKeyJustPressed(ebiten.KeySpace):
-		if app.tracker.EatInput() {
-			app.tracker.Input([]rune(" "))
-		} else {
-			app.timer.Toggle()
+	for _, event := range app.events.next(app.pollEvents(), ebiten.IsKeyPressed) {
+		if err := app.dispatch(event); err != nil {
+			return err
 		}
+	}
+	app.syncHintProfile()
 
-	case inpututil.IsKeyJustPressed(ebiten.KeyDelete):
-		app.timer.Reset()
+	return nil
+}
 
-	case inpututil.IsKeyJustPressed(ebiten.KeyBackspace):
-		app.tracker.Backspace()
+// watchConfig reloads the config file when it changes on disk and applies it
+// while keeping the current tracking state.
+func (app *App) watchConfig() {
+	if time.Now().Before(app.nextConfigCheck) {
+		return
+	}
+	app.nextConfigCheck = time.Now().Add(configPollInterval)
 
-	case inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft):
-		app.tracker.ClickLeft(ebiten.CursorPosition())
+	stat, err := os.Stat(app.opts.configPath)
+	if err != nil {
+		log.Printf("warning: %s", err)
+		return
+	}
 
-	case inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight):
-		app.tracker.ClickRight(ebiten.CursorPosition())
+	if stat.ModTime().Equal(app.configModTime) {
+		return
+	}
+	app.configModTime = stat.ModTime()
 
-	case wheel != 0:
-		x, y := ebiten.CursorPosition()
-		app.tracker.Wheel(x, y, wheel > 0)
+	config, err := loadConfig(app.opts)
+	if err != nil {
+		log.Printf("error: %s", err)
+		return
+	}
 
-	default:
-		app.tracker.Input(ebiten.InputChars())
+	log.Printf("reloading %s", app.opts.configPath)
+	app.setConfig(config)
+	app.tracker.Reconfigure(config.trackerConfig())
+}
+
+// setConfig applies the window and timer part of a new config, the tracker is
+// left to the caller.
+func (app *App) setConfig(config config) {
+	if size := config.windowSize(); size != app.config.windowSize() {
+		ebiten.SetWindowSize(size.X, size.Y)
 	}
 
-	return nil
+	app.timer.SetDimensions(config.Dimensions.Timer)
+	app.config = config
 }
 
 func (app *App) Draw(screen *ebiten.Image) {
 	app.tracker.Draw(screen)
 	app.timer.Draw(screen)
+
+	if app.pendingSession != nil {
+		app.drawRestorePrompt(screen)
+	}
 }
 
 func (app *App) Layout(w, h int) (int, int) {
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"fmt"
+	"ivan/tracker"
+	"log"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+// Actions handled by the app itself, keys can also be bound to any
+// tracker.Action.
+const (
+	actionQuit                   = "quit" // cancels the tracker input first
+	actionReset                  = "reset"
+	actionRestorePreviousSession = "restorePreviousSession"
+	actionToggleTimer            = "toggleTimer"
+	actionResetTimer             = "resetTimer"
+	actionBackspace              = "backspace"
+	actionDeleteWord             = "deleteWord"
+	actionNextCandidate          = "nextCandidate"
+	actionPreviousCandidate      = "previousCandidate"
+	actionNavigateUp             = "navigateUp"
+	actionNavigateDown           = "navigateDown"
+	actionNavigateLeft           = "navigateLeft"
+	actionNavigateRight          = "navigateRight"
+	actionLineStart              = "lineStart"
+	actionLineEnd                = "lineEnd"
+	actionNextHintProfile        = "nextHintProfile"
+)
+
+// nolint:gochecknoglobals
+var appActions = map[string]struct{}{
+	actionQuit:                   {},
+	actionReset:                  {},
+	actionRestorePreviousSession: {},
+	actionToggleTimer:            {},
+	actionResetTimer:             {},
+	actionBackspace:              {},
+	actionDeleteWord:             {},
+	actionNextCandidate:          {},
+	actionPreviousCandidate:      {},
+	actionNavigateUp:             {},
+	actionNavigateDown:           {},
+	actionNavigateLeft:           {},
+	actionNavigateRight:          {},
+	actionLineStart:              {},
+	actionLineEnd:                {},
+	actionNextHintProfile:        {},
+}
+
+// bindings maps physical keys and text input runes to actions.
+type bindings struct {
+	// Key combination (eg. "KP7", "Ctrl+Z", or "Shift+Tab") to app or tracker
+	// action. Keypad keys are distinct from the main keyboard ones.
+	Keys map[string]string
+
+	// Key combinations applied instead of Keys while the tracker is eating
+	// input, eg. to move the cursor with keys that are otherwise bound to
+	// something else.
+	TextKeys map[string]string
+
+	// Typed character to app or tracker action, these follow the keyboard
+	// layout and are ignored while typing text.
+	Runes map[string]string
+}
+
+func defaultKeys() map[string]string {
+	return map[string]string{
+		"Escape":         actionQuit,
+		"Home":           actionReset,
+		"End":            actionRestorePreviousSession,
+		"Enter":          string(tracker.ActionSubmit),
+		"KPEnter":        string(tracker.ActionSubmit),
+		"Space":          actionToggleTimer,
+		"Delete":         actionResetTimer,
+		"Backspace":      actionBackspace,
+		"Ctrl+Backspace": actionDeleteWord,
+		"Ctrl+W":         actionDeleteWord,
+		"Tab":            actionNextCandidate,
+		"Shift+Tab":      actionPreviousCandidate,
+		"Up":             actionNavigateUp,
+		"Down":           actionNavigateDown,
+		"Left":           actionNavigateLeft,
+		"Right":          actionNavigateRight,
+		"F2":             actionNextHintProfile,
+	}
+}
+
+func defaultTextKeys() map[string]string {
+	return map[string]string{
+		"Home": actionLineStart,
+		"End":  actionLineEnd,
+	}
+}
+
+func defaultRunes() map[string]string {
+	ret := make(map[string]string)
+	for k, v := range tracker.DefaultRunes() {
+		ret[k] = string(v)
+	}
+
+	return ret
+}
+
+// trackerRunes returns the rune bindings handled by the tracker.
+func (b bindings) trackerRunes() map[string]tracker.Action {
+	ret := make(map[string]tracker.Action, len(b.Runes))
+	for k, v := range b.Runes {
+		if tracker.IsAction(tracker.Action(v)) {
+			ret[k] = tracker.Action(v)
+		}
+	}
+
+	return ret
+}
+
+// boundKeys returns the names of the keys bound to any of the given actions,
+// as written in the configuration.
+func (app *App) boundKeys(actions ...string) string {
+	var ret []string
+	for k, v := range app.config.Bindings.Keys {
+		for _, action := range actions {
+			if v == action {
+				ret = append(ret, k)
+			}
+		}
+	}
+	sort.Strings(ret)
+
+	return strings.Join(ret, "/")
+}
+
+func isAction(name string) bool {
+	_, ok := appActions[name]
+	return ok || tracker.IsAction(tracker.Action(name))
+}
+
+// keyCombo is a key pressed along with exactly these modifiers.
+type keyCombo struct {
+	key              ebiten.Key
+	ctrl, shift, alt bool
+}
+
+// nolint:gochecknoglobals
+var keysByName = func() map[string]ebiten.Key {
+	ret := make(map[string]ebiten.Key, ebiten.KeyMax+1)
+	for k := ebiten.Key(0); k <= ebiten.KeyMax; k++ {
+		ret[strings.ToLower(k.String())] = k
+	}
+	return ret
+}()
+
+// parseKeyCombo parses a key combination, modifiers are joined to the key with
+// "+", eg. "Ctrl+Shift+Z".
+func parseKeyCombo(str string) (keyCombo, error) {
+	var ret keyCombo
+	parts := strings.Split(str, "+")
+	for _, v := range parts[:len(parts)-1] {
+		switch strings.ToLower(v) {
+		case "ctrl", "control":
+			ret.ctrl = true
+		case "shift":
+			ret.shift = true
+		case "alt":
+			ret.alt = true
+		default:
+			return keyCombo{}, fmt.Errorf("unknown modifier %q", v)
+		}
+	}
+
+	key, ok := keysByName[strings.ToLower(parts[len(parts)-1])]
+	if !ok {
+		return keyCombo{}, fmt.Errorf("unknown key %q", parts[len(parts)-1])
+	}
+	ret.key = key
+
+	return ret, nil
+}
+
+// parseKeys returns the parsed key bindings, invalid ones are skipped as they
+// are reported by config validation.
+func parseKeys(keys map[string]string) map[keyCombo]string {
+	ret := make(map[keyCombo]string, len(keys))
+	for k, v := range keys {
+		combo, err := parseKeyCombo(k)
+		if err != nil {
+			continue
+		}
+		ret[combo] = v
+	}
+
+	return ret
+}
+
+// typesText returns true if the key combination produces a character, it is
+// then left to the text input while the tracker is eating input.
+func (c keyCombo) typesText() bool {
+	if c.ctrl || c.alt {
+		return false
+	}
+
+	switch c.key {
+	case ebiten.KeySpace, ebiten.KeyApostrophe, ebiten.KeyBackslash, ebiten.KeyComma,
+		ebiten.KeyEqual, ebiten.KeyGraveAccent, ebiten.KeyLeftBracket, ebiten.KeyMinus,
+		ebiten.KeyPeriod, ebiten.KeyRightBracket, ebiten.KeySemicolon, ebiten.KeySlash,
+		ebiten.KeyKPAdd, ebiten.KeyKPDecimal, ebiten.KeyKPDivide, ebiten.KeyKPEqual,
+		ebiten.KeyKPMultiply, ebiten.KeyKPSubtract:
+		return true
+	}
+
+	return (c.key >= ebiten.Key0 && c.key <= ebiten.Key9) ||
+		(c.key >= ebiten.KeyA && c.key <= ebiten.KeyZ) ||
+		(c.key >= ebiten.KeyKP0 && c.key <= ebiten.KeyKP9)
+}
+
+// types returns true if the key combination may have typed r. Only the keys
+// that do not depend on the keyboard layout are known, other keys never match.
+func (c keyCombo) types(r rune) bool {
+	switch {
+	case c.key >= ebiten.KeyKP0 && c.key <= ebiten.KeyKP9:
+		return r == '0'+rune(c.key-ebiten.KeyKP0)
+	case c.key >= ebiten.KeyA && c.key <= ebiten.KeyZ:
+		return unicode.ToUpper(r) == 'A'+rune(c.key-ebiten.KeyA)
+	case c.key >= ebiten.Key0 && c.key <= ebiten.Key9 && !c.shift:
+		return r == '0'+rune(c.key-ebiten.Key0)
+	}
+
+	switch c.key {
+	case ebiten.KeySpace:
+		return r == ' '
+	case ebiten.KeyKPAdd:
+		return r == '+'
+	case ebiten.KeyKPSubtract:
+		return r == '-'
+	case ebiten.KeyKPMultiply:
+		return r == '*'
+	case ebiten.KeyKPDivide:
+		return r == '/'
+	case ebiten.KeyKPDecimal:
+		return r == '.' || r == ','
+	case ebiten.KeyKPEqual:
+		return r == '='
+	default:
+		return false
+	}
+}
+
+// input sends a typed rune to the tracker, runes bound to app actions are
+// applied instead unless the tracker is eating input.
+func (app *App) input(r rune) error {
+	action, ok := app.config.Bindings.Runes[string(r)]
+	if ok && !app.tracker.EatInput() && !tracker.IsAction(tracker.Action(action)) {
+		return app.do(action)
+	}
+
+	app.tracker.Input([]rune{r})
+	return nil
+}
+
+// do applies an app or tracker action.
+func (app *App) do(action string) error {
+	switch action {
+	case actionQuit:
+		if !app.timer.IsRunning() && !app.tracker.EatInput() {
+			app.saveSession()
+			app.saveRanking()
+			return errCloseApp
+		}
+		app.tracker.Cancel()
+
+	case actionReset:
+		if !app.timer.IsRunning() && !app.tracker.EatInput() {
+			config, err := loadConfig(app.opts)
+			if err != nil {
+				// Keep the current config instead of quitting mid-session.
+				log.Printf("error: %s", err)
+				break
+			}
+			app.resetSession(config)
+		}
+
+	case actionRestorePreviousSession:
+		if !app.timer.IsRunning() && !app.tracker.EatInput() {
+			app.restorePreviousSession()
+		}
+
+	case actionNextHintProfile:
+		if !app.timer.IsRunning() && !app.tracker.EatInput() {
+			app.nextHintProfile()
+		}
+
+	case actionToggleTimer:
+		app.timer.Toggle()
+		app.journal.recordTimer(timerActionToggle)
+
+	case actionResetTimer:
+		wasRunning := app.timer.IsRunning()
+		app.timer.Reset()
+		if wasRunning && !app.timer.IsRunning() {
+			app.journal.recordTimer(timerActionReset)
+		}
+
+	case actionBackspace:
+		app.tracker.Backspace()
+	case actionDeleteWord:
+		app.tracker.DeleteWord()
+
+	case actionNextCandidate:
+		app.tracker.CycleCandidate(1)
+	case actionPreviousCandidate:
+		app.tracker.CycleCandidate(-1)
+
+	case actionNavigateUp:
+		app.tracker.Navigate(0, -1)
+	case actionNavigateDown:
+		app.tracker.Navigate(0, 1)
+	case actionNavigateLeft:
+		app.tracker.Navigate(-1, 0)
+	case actionNavigateRight:
+		app.tracker.Navigate(1, 0)
+
+	case actionLineStart:
+		app.tracker.CursorToStart()
+	case actionLineEnd:
+		app.tracker.CursorToEnd()
+
+	default:
+		app.tracker.Trigger(tracker.Action(action))
+	}
+
+	return nil
+}
@@ -2,20 +2,40 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"image"
 	"ivan/tracker"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
 type config struct {
-	Items       []tracker.Item
-	ZoneItemMap [9][9]string
-	Locations   []string // woth/barren "simple" locations
-	Dimensions  struct {
+	Items            []tracker.Item
+	StartingItems    []tracker.StartingItem
+	ZoneItemMap      [9][9]string
+	Locations        []string          // woth/barren "simple" locations
+	Checks           []string          // sometimes hint locations, defaults to Locations
+	DungeonLocations []string          // Locations that are dungeons
+	LocationAliases  map[string]string // alias -> location or check
+	Dungeons         []tracker.Dungeon // defaults to tracker.DefaultDungeons
+	DungeonRewards   []string          // items accepting a dungeon, defaults to IsMedallion items
+	DungeonPanel     []tracker.PanelDungeon
+	HintCategories   []tracker.HintCategory
+	HintProfile      string // key of the HintProfiles entry to use, if any
+	HintProfiles     map[string]tracker.HintProfile
+	Bindings         bindings // defaults to defaultKeys and tracker.DefaultRunes
+	Dimensions       struct {
 		ItemTracker image.Rectangle
 		Timer       image.Rectangle
 		HintTracker image.Rectangle
+
+		// Dungeon panel, only required if DungeonPanel is set.
+		DungeonTracker image.Rectangle
 	}
+
+	keys, textKeys map[keyCombo]string // parsed Bindings.Keys and TextKeys
 }
 
 func (c config) windowSize() image.Point {
@@ -24,6 +44,7 @@ func (c config) windowSize() image.Point {
 		c.Dimensions.ItemTracker,
 		c.Dimensions.Timer,
 		c.Dimensions.HintTracker,
+		c.Dimensions.DungeonTracker,
 	} {
 		ret = ret.Union(v)
 	}
@@ -31,8 +52,32 @@ func (c config) windowSize() image.Point {
 	return ret.Size()
 }
 
-func loadConfig(path string) (config, error) {
-	f, err := os.Open(path)
+func (c config) trackerConfig() tracker.Config {
+	return tracker.Config{
+		Dimensions:        c.Dimensions.ItemTracker,
+		HintDimensions:    c.Dimensions.HintTracker,
+		DungeonDimensions: c.Dimensions.DungeonTracker,
+		PanelDungeons:     c.DungeonPanel,
+		Items:             c.Items,
+		StartingItems:     c.StartingItems,
+		ZoneItemMap:       c.ZoneItemMap,
+		Locations:         c.Locations,
+		Checks:            c.Checks,
+		DungeonLocations:  c.DungeonLocations,
+		LocationAliases:   c.LocationAliases,
+		Dungeons:          c.Dungeons,
+		DungeonRewards:    c.DungeonRewards,
+		HintCategories:    c.HintCategories,
+		HintProfiles:      c.HintProfiles,
+		HintProfile:       c.HintProfile,
+		Runes:             c.Bindings.trackerRunes(),
+	}
+}
+
+// loadConfig loads and validates the configuration file given in opts, the
+// hint profile given in opts overrides the one from the file.
+func loadConfig(opts options) (config, error) {
+	f, err := os.Open(opts.configPath)
 	if err != nil {
 		return config{}, err
 	}
@@ -44,5 +89,501 @@ func loadConfig(path string) (config, error) {
 		return config{}, err
 	}
 
+	if opts.hintProfile != "" {
+		ret.HintProfile = opts.hintProfile
+	}
+
+	if ret.Bindings.Keys == nil {
+		ret.Bindings.Keys = defaultKeys()
+	}
+	if ret.Bindings.TextKeys == nil {
+		ret.Bindings.TextKeys = defaultTextKeys()
+	}
+	if ret.Bindings.Runes == nil {
+		ret.Bindings.Runes = defaultRunes()
+	}
+
+	// Older configurations flag rewards on the items and have no dungeons.
+	if ret.Dungeons == nil {
+		ret.Dungeons = tracker.DefaultDungeons()
+	}
+	if ret.DungeonRewards == nil {
+		for _, v := range ret.Items {
+			if v.IsMedallion {
+				ret.DungeonRewards = append(ret.DungeonRewards, v.Name)
+			}
+		}
+	}
+
+	sheet, err := imageBounds(filepath.Join(opts.assetsDir, "items.png"))
+	if err != nil {
+		return config{}, err
+	}
+
+	if err := ret.validate(sheet); err != nil {
+		return config{}, fmt.Errorf("%s: %w", opts.configPath, err)
+	}
+	ret.keys = parseKeys(ret.Bindings.Keys)
+	ret.textKeys = parseKeys(ret.Bindings.TextKeys)
+
 	return ret, nil
 }
+
+func imageBounds(path string) (image.Rectangle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	defer f.Close()
+
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+
+	return image.Rect(0, 0, cfg.Width, cfg.Height), nil
+}
+
+// configErrors holds every problem found in a config, prefixed by their JSON
+// path.
+type configErrors []string
+
+func (errs configErrors) Error() string {
+	return fmt.Sprintf(
+		"%d configuration error(s):\n\t%s",
+		len(errs), strings.Join(errs, "\n\t"),
+	)
+}
+
+func (errs *configErrors) add(path string, format string, args ...interface{}) {
+	*errs = append(*errs, path+": "+fmt.Sprintf(format, args...))
+}
+
+// validate checks the config for inconsistencies that would otherwise surface
+// at runtime, sheet is the bounds of the items spritesheet.
+func (c config) validate(sheet image.Rectangle) error {
+	var errs configErrors
+
+	c.validateItems(&errs, sheet)
+	c.validateItemNames(&errs)
+	c.validateDimensions(&errs)
+	c.validateHintCategories(&errs)
+	c.validateHintProfiles(&errs)
+	c.validateBindings(&errs)
+
+	validateUnique(&errs, "Locations", c.Locations)
+	validateUnique(&errs, "Checks", c.Checks)
+	validateUnique(&errs, "DungeonLocations", c.DungeonLocations)
+	c.validateDungeonLocations(&errs)
+	c.validateLocationAliases(&errs)
+	c.validateDungeons(&errs)
+	c.validateDungeonPanel(&errs)
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (c config) validateItems(errs *configErrors, sheet image.Rectangle) {
+	names := make(map[string]int, len(c.Items))
+	for k, item := range c.Items {
+		path := fmt.Sprintf("Items[%d]", k)
+
+		if item.Name == "" {
+			errs.add(path+".Name", "missing name")
+		} else if prev, ok := names[item.Name]; ok {
+			errs.add(path+".Name", "duplicate of Items[%d] %q", prev, item.Name)
+		} else {
+			names[item.Name] = k
+		}
+
+		if len(item.ItemProgression) > 0 && len(item.CapacityProgression) > 0 &&
+			len(item.ItemProgression) != len(item.CapacityProgression) {
+			errs.add(
+				path+".CapacityProgression",
+				"%q has %d capacities for %d items, they must be of the same length",
+				item.Name, len(item.CapacityProgression), len(item.ItemProgression),
+			)
+		}
+
+		if item.IsCountable() && item.CountStep <= 0 {
+			errs.add(path+".CountStep", "%q is countable but has no positive step", item.Name)
+		}
+
+		if len(item.ItemProgression) == 0 && !item.SheetRect().In(sheet) {
+			errs.add(path, "%q sprite is outside the spritesheet %s", item.Name, sheet.Max)
+		}
+		for i, v := range item.ItemProgression {
+			if !v.SheetRect().In(sheet) {
+				errs.add(
+					fmt.Sprintf("%s.ItemProgression[%d]", path, i),
+					"%q sprite is outside the spritesheet %s", v.Name, sheet.Max,
+				)
+			}
+		}
+
+		// Songs are stacked and slightly overlap, only complain if an item
+		// would shadow most of another one.
+		center := item.Rect().Min.Add(item.Rect().Size().Div(2))
+		for i := 0; i < k; i++ {
+			if center.In(c.Items[i].Rect()) {
+				errs.add(path, "%q overlaps Items[%d] %q", item.Name, i, c.Items[i].Name)
+			}
+		}
+	}
+}
+
+// validateItemNames ensures item names referenced outside of Items exist.
+func (c config) validateItemNames(errs *configErrors) {
+	names := make(map[string]struct{}, len(c.Items))
+	for _, v := range c.Items {
+		names[v.Name] = struct{}{}
+	}
+
+	for zone := range c.ZoneItemMap {
+		for k, v := range c.ZoneItemMap[zone] {
+			if _, ok := names[v]; v != "" && !ok {
+				errs.add(fmt.Sprintf("ZoneItemMap[%d][%d]", zone, k), "unknown item %q", v)
+			}
+		}
+	}
+
+	for k, v := range c.StartingItems {
+		if _, ok := names[v.Name]; !ok {
+			errs.add(fmt.Sprintf("StartingItems[%d].Name", k), "unknown item %q", v.Name)
+		}
+	}
+
+	for k, v := range c.DungeonRewards {
+		if _, ok := names[v]; !ok {
+			errs.add(fmt.Sprintf("DungeonRewards[%d]", k), "unknown item %q", v)
+		}
+	}
+}
+
+func (c config) validateDimensions(errs *configErrors) {
+	type dimension struct {
+		name string
+		rect image.Rectangle
+	}
+	dimensions := []dimension{
+		{"ItemTracker", c.Dimensions.ItemTracker},
+		{"Timer", c.Dimensions.Timer},
+		{"HintTracker", c.Dimensions.HintTracker},
+	}
+	if len(c.DungeonPanel) > 0 || !c.Dimensions.DungeonTracker.Empty() {
+		dimensions = append(dimensions, dimension{"DungeonTracker", c.Dimensions.DungeonTracker})
+	}
+
+	for k, v := range dimensions {
+		path := "Dimensions." + v.name
+		if v.rect.Empty() {
+			errs.add(path, "empty rectangle %s", v.rect)
+			continue
+		}
+
+		for _, other := range dimensions[:k] {
+			if v.rect.Overlaps(other.rect) {
+				errs.add(path, "overlaps Dimensions.%s", other.name)
+			}
+		}
+	}
+}
+
+func (c config) validateHintCategories(errs *configErrors) {
+	names := make(map[string]int, len(c.HintCategories))
+	hotkeys := make(map[string]int, len(c.HintCategories))
+	bounds := image.Rectangle{Max: c.Dimensions.HintTracker.Size()}
+
+	for k, cat := range c.HintCategories {
+		path := fmt.Sprintf("HintCategories[%d]", k)
+
+		if cat.Name == "" {
+			errs.add(path+".Name", "missing name")
+		} else if prev, ok := names[cat.Name]; ok {
+			errs.add(path+".Name", "duplicate of HintCategories[%d] %q", prev, cat.Name)
+		} else {
+			names[cat.Name] = k
+		}
+
+		if hotkey := []rune(cat.Hotkey); len(hotkey) != 1 {
+			errs.add(path+".Hotkey", "must be a single character, got %q", cat.Hotkey)
+		} else if action, ok := c.Bindings.Runes[cat.Hotkey]; ok {
+			errs.add(path+".Hotkey", "%q is already bound to %s", cat.Hotkey, action)
+		} else if prev, ok := hotkeys[cat.Hotkey]; ok {
+			errs.add(path+".Hotkey", "%q already used by HintCategories[%d]", cat.Hotkey, prev)
+		} else {
+			hotkeys[cat.Hotkey] = k
+		}
+
+		switch cat.Match {
+		case "", tracker.HintMatchFreeform, tracker.HintMatchLocation, tracker.HintMatchItem,
+			tracker.HintMatchLocationItem:
+		default:
+			errs.add(path+".Match", "unknown match %q", cat.Match)
+		}
+
+		switch cat.LocationKind {
+		case tracker.LocationKindAny, tracker.LocationKindDungeon, tracker.LocationKindOverworld:
+		default:
+			errs.add(path+".LocationKind", "unknown location kind %q", cat.LocationKind)
+		}
+
+		if cat.Capacity < 0 {
+			errs.add(path+".Capacity", "negative capacity %d", cat.Capacity)
+		}
+
+		for i, v := range cat.Regions {
+			if v.Empty() || !v.In(bounds) {
+				errs.add(
+					fmt.Sprintf("%s.Regions[%d]", path, i),
+					"%s must be a non-empty rectangle inside the hint tracker %s", v, bounds,
+				)
+			}
+		}
+
+		validateHintSlots(errs, path+".Slots", cat.Slots)
+	}
+}
+
+// validateHintProfiles checks the profiles, which are optional: without a
+// HintProfile the categories keep their own Capacity and Slots.
+func (c config) validateHintProfiles(errs *configErrors) {
+	if _, ok := c.HintProfiles[c.HintProfile]; c.HintProfile != "" && !ok {
+		errs.add("HintProfile", "unknown profile %q", c.HintProfile)
+	}
+
+	categories := make(map[string]struct{}, len(c.HintCategories))
+	for _, v := range c.HintCategories {
+		categories[v.Name] = struct{}{}
+	}
+
+	for name, profile := range c.HintProfiles {
+		for category, dist := range profile {
+			path := fmt.Sprintf("HintProfiles[%q][%q]", name, category)
+			if _, ok := categories[category]; !ok {
+				errs.add(path, "unknown hint category")
+			}
+
+			if dist.Capacity < 0 {
+				errs.add(path+".Capacity", "negative capacity %d", dist.Capacity)
+			}
+
+			validateHintSlots(errs, path+".Slots", dist.Slots)
+		}
+	}
+}
+
+func validateHintSlots(errs *configErrors, path string, slots []tracker.HintSlot) {
+	names := make(map[string]int, len(slots))
+	aliases := make(map[string]int)
+	for k, slot := range slots {
+		slotPath := fmt.Sprintf("%s[%d]", path, k)
+		if slot.Name == "" {
+			errs.add(slotPath+".Name", "missing name")
+		} else if prev, ok := names[slot.Name]; ok {
+			errs.add(slotPath+".Name", "duplicate of slot %d %q", prev, slot.Name)
+		} else {
+			names[slot.Name] = k
+		}
+
+		for i, alias := range slot.Aliases {
+			alias = strings.ToLower(alias)
+			if prev, ok := aliases[alias]; ok {
+				errs.add(
+					fmt.Sprintf("%s.Aliases[%d]", slotPath, i),
+					"alias %q already used by slot %d", alias, prev,
+				)
+				continue
+			}
+			aliases[alias] = k
+		}
+	}
+}
+
+// validateUnique checks that a list of names has no duplicates.
+func validateUnique(errs *configErrors, path string, names []string) {
+	seen := make(map[string]int, len(names))
+	for k, v := range names {
+		if prev, ok := seen[v]; ok {
+			errs.add(fmt.Sprintf("%s[%d]", path, k), "duplicate of %s[%d] %q", path, prev, v)
+			continue
+		}
+		seen[v] = k
+	}
+}
+
+func (c config) validateDungeonLocations(errs *configErrors) {
+	locations := make(map[string]struct{}, len(c.Locations))
+	for _, v := range c.Locations {
+		locations[v] = struct{}{}
+	}
+
+	for k, v := range c.DungeonLocations {
+		if _, ok := locations[v]; !ok {
+			errs.add(fmt.Sprintf("DungeonLocations[%d]", k), "%q is not in Locations", v)
+		}
+	}
+}
+
+// dungeonLocationSet returns the DungeonLocations, the names shared by hints,
+// rewards and the dungeon panel.
+func (c config) dungeonLocationSet() map[string]struct{} {
+	ret := make(map[string]struct{}, len(c.DungeonLocations))
+	for _, v := range c.DungeonLocations {
+		ret[v] = struct{}{}
+	}
+
+	return ret
+}
+
+func (c config) validateLocationAliases(errs *configErrors) {
+	names := make(map[string]struct{}, len(c.Locations)+len(c.Checks))
+	for _, v := range append(append([]string(nil), c.Locations...), c.Checks...) {
+		names[v] = struct{}{}
+	}
+
+	aliases := make([]string, 0, len(c.LocationAliases))
+	for k := range c.LocationAliases {
+		aliases = append(aliases, k)
+	}
+	sort.Strings(aliases)
+
+	for _, alias := range aliases {
+		name := c.LocationAliases[alias]
+		if _, ok := names[name]; !ok {
+			errs.add(fmt.Sprintf("LocationAliases[%q]", alias), "%q is not in Locations or Checks", name)
+		}
+	}
+}
+
+// validateDungeons ensures dungeons can be told apart by name and label, that
+// unique ones are dungeon locations, and that starting items only reference
+// known dungeons.
+func (c config) validateDungeons(errs *configErrors) {
+	locations := c.dungeonLocationSet()
+	seen := make(map[string]int, 2*len(c.Dungeons))
+	for k, v := range c.Dungeons {
+		path := fmt.Sprintf("Dungeons[%d]", k)
+		if v.Name == "" {
+			errs.add(path+".Name", "missing name")
+			continue
+		}
+		if _, ok := locations[v.Name]; v.Unique && len(locations) > 0 && !ok {
+			errs.add(path+".Name", "%q is unique but not in DungeonLocations", v.Name)
+		}
+
+		for _, name := range []string{v.Name, v.Label} {
+			key := strings.ToLower(name)
+			if prev, ok := seen[key]; ok && prev != k {
+				errs.add(path, "%q is already used by Dungeons[%d]", name, prev)
+			}
+			if name != "" {
+				seen[key] = k
+			}
+		}
+	}
+
+	if len(c.DungeonRewards) > 0 && len(c.Dungeons) == 0 {
+		errs.add("Dungeons", "%d DungeonRewards but no dungeon to assign them", len(c.DungeonRewards))
+	}
+
+	validateUnique(errs, "DungeonRewards", c.DungeonRewards)
+	rewards := make(map[string]struct{}, len(c.DungeonRewards))
+	for _, v := range c.DungeonRewards {
+		rewards[v] = struct{}{}
+	}
+
+	for k, v := range c.StartingItems {
+		if v.Temple == "" {
+			continue
+		}
+
+		path := fmt.Sprintf("StartingItems[%d].Temple", k)
+		if _, ok := seen[strings.ToLower(v.Temple)]; !ok {
+			errs.add(path, "unknown dungeon %q", v.Temple)
+		}
+		if _, ok := rewards[v.Name]; !ok {
+			errs.add(path, "%q is not in DungeonRewards", v.Name)
+		}
+	}
+}
+
+// validateDungeonPanel ensures panel dungeons are named after dungeon
+// locations and fit in the panel.
+func (c config) validateDungeonPanel(errs *configErrors) {
+	locations := c.dungeonLocationSet()
+	names := make([]string, len(c.DungeonPanel))
+	for k, v := range c.DungeonPanel {
+		path := fmt.Sprintf("DungeonPanel[%d]", k)
+		names[k] = v.Name
+		if v.Name == "" {
+			errs.add(path+".Name", "missing name")
+		} else if _, ok := locations[v.Name]; len(locations) > 0 && !ok {
+			errs.add(path+".Name", "%q is not in DungeonLocations", v.Name)
+		}
+		if v.Keys < 0 || v.MQKeys < 0 {
+			errs.add(path, "%q has a negative number of keys", v.Name)
+		}
+	}
+	validateUnique(errs, "DungeonPanel", names)
+
+	if height := len(c.DungeonPanel) * tracker.DungeonLineHeight; height > c.Dimensions.DungeonTracker.Dy() {
+		errs.add(
+			"Dimensions.DungeonTracker", "%d dungeons need a height of %dpx, got %dpx",
+			len(c.DungeonPanel), height, c.Dimensions.DungeonTracker.Dy(),
+		)
+	}
+}
+
+func (c config) validateBindings(errs *configErrors) {
+	validateKeys(errs, "Bindings.Keys", c.Bindings.Keys)
+	validateKeys(errs, "Bindings.TextKeys", c.Bindings.TextKeys)
+
+	runes := make([]string, 0, len(c.Bindings.Runes))
+	for k := range c.Bindings.Runes {
+		runes = append(runes, k)
+	}
+	sort.Strings(runes)
+
+	for _, k := range runes {
+		path := fmt.Sprintf("Bindings.Runes[%q]", k)
+		if len([]rune(k)) != 1 {
+			errs.add(path, "must be a single character")
+		}
+
+		if action := c.Bindings.Runes[k]; !isAction(action) {
+			errs.add(path, "unknown action %q", action)
+		}
+	}
+}
+
+// validateKeys checks the key combinations of a key bindings section.
+func validateKeys(errs *configErrors, section string, bindings map[string]string) {
+	keys := make([]string, 0, len(bindings))
+	for k := range bindings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	seen := make(map[keyCombo]string, len(keys))
+	for _, k := range keys {
+		path := fmt.Sprintf("%s[%q]", section, k)
+		combo, err := parseKeyCombo(k)
+		if err != nil {
+			errs.add(path, "%s", err)
+			continue
+		}
+
+		if prev, ok := seen[combo]; ok {
+			errs.add(path, "same key combination as %q", prev)
+		}
+		seen[combo] = k
+
+		if action := bindings[k]; !isAction(action) {
+			errs.add(path, "unknown action %q", action)
+		}
+	}
+}
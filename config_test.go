@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"ivan/tracker"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testOptions() options {
+	return options{
+		configPath: filepath.Join("assets", "config.json"),
+		assetsDir:  "assets",
+	}
+}
+
+func TestLoadShippedConfig(t *testing.T) {
+	c, err := loadConfig(testOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name := range c.HintProfiles {
+		opts := testOptions()
+		opts.hintProfile = name
+		if _, err := loadConfig(opts); err != nil {
+			t.Errorf("profile %s: %s", name, err)
+		}
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	sheet, err := imageBounds(filepath.Join("assets", "items.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name   string
+		change func(*config)
+		want   string // expected error, none if empty
+	}{
+		{
+			name:   "unchanged",
+			change: func(c *config) {},
+		},
+		{
+			name:   "no profile",
+			change: func(c *config) { c.HintProfile, c.HintProfiles = "", nil },
+		},
+		{
+			name:   "unknown profile",
+			change: func(c *config) { c.HintProfile = "nope" },
+			want:   `HintProfile: unknown profile "nope"`,
+		},
+		{
+			name: "profile of an unknown category",
+			change: func(c *config) {
+				c.HintProfiles["tournament"]["Nope"] = tracker.HintDistribution{Capacity: 1}
+			},
+			want: `HintProfiles["tournament"]["Nope"]: unknown hint category`,
+		},
+		{
+			name: "negative profile capacity",
+			change: func(c *config) {
+				c.HintProfiles["tournament"][c.HintCategories[0].Name] = tracker.HintDistribution{Capacity: -1}
+			},
+			want: "negative capacity -1",
+		},
+		{
+			name:   "duplicate location",
+			change: func(c *config) { c.Locations = append(c.Locations, c.Locations[0]) },
+			want:   "duplicate of Locations[0]",
+		},
+		{
+			name:   "dungeon location that is not a location",
+			change: func(c *config) { c.DungeonLocations = append(c.DungeonLocations, "Nowhere") },
+			want:   `"Nowhere" is not in Locations`,
+		},
+		{
+			name:   "hotkey bound to an action",
+			change: func(c *config) { c.HintCategories[0].Hotkey = "+" },
+			want:   `HintCategories[0].Hotkey: "+" is already bound to`,
+		},
+		{
+			name:   "rewards without dungeons",
+			change: func(c *config) { c.Dungeons = []tracker.Dungeon{} },
+			want:   "DungeonRewards but no dungeon to assign them",
+		},
+		{
+			name:   "panel dungeon that is not a dungeon location",
+			change: func(c *config) { c.DungeonPanel[1].Name = "Dodongos Cavern" },
+			want:   `DungeonPanel[1].Name: "Dodongos Cavern" is not in DungeonLocations`,
+		},
+		{
+			name:   "unique dungeon that is not a dungeon location",
+			change: func(c *config) { c.Dungeons[2].Name = "Dodongos Cavern" },
+			want:   `"Dodongos Cavern" is unique but not in DungeonLocations`,
+		},
+		{
+			name:   "panel too short",
+			change: func(c *config) { c.Dimensions.DungeonTracker.Max.Y = c.Dimensions.DungeonTracker.Min.Y + 20 },
+			want:   "Dimensions.DungeonTracker: 12 dungeons need a height",
+		},
+		{
+			name:   "unknown starting item",
+			change: func(c *config) { c.StartingItems = append(c.StartingItems, tracker.StartingItem{Name: "Nope"}) },
+			want:   `unknown item "Nope"`,
+		},
+	} {
+		cfg, err := loadConfig(testOptions())
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.change(&cfg)
+
+		err = cfg.validate(sheet)
+		switch {
+		case c.want == "" && err != nil:
+			t.Errorf("%s: unexpected error: %s", c.name, err)
+		case c.want != "" && err == nil:
+			t.Errorf("%s: expected an error containing %q", c.name, c.want)
+		case c.want != "" && !strings.Contains(err.Error(), c.want):
+			t.Errorf("%s: expected an error containing %q, got: %s", c.name, c.want, err)
+		}
+	}
+}
+
+// TestLoadOlderConfig checks that dungeons and rewards default to the vanilla
+// ones and the IsMedallion items when a configuration lists neither.
+func TestLoadOlderConfig(t *testing.T) {
+	shipped, err := loadConfig(testOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(testOptions().configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	delete(raw, "Dungeons")
+	delete(raw, "DungeonRewards")
+	for _, v := range raw["Items"].([]interface{}) {
+		item := v.(map[string]interface{})
+		for _, reward := range shipped.DungeonRewards {
+			if item["Name"] == reward {
+				item["IsMedallion"] = true
+			}
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "ivan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	opts := testOptions()
+	opts.configPath = filepath.Join(dir, "config.json")
+	if data, err = json.Marshal(raw); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(opts.configPath, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	older, err := loadConfig(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(older.Dungeons, tracker.DefaultDungeons()) {
+		t.Errorf("dungeons: got %v, want the default ones", older.Dungeons)
+	}
+	if !reflect.DeepEqual(older.DungeonRewards, shipped.DungeonRewards) {
+		t.Errorf("rewards: got %v, want %v", older.DungeonRewards, shipped.DungeonRewards)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"image"
+	"sort"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/inpututil"
+)
+
+type inputEventKind int
+
+const (
+	// Typed rune, along with the key that typed it if it is known. Keys that
+	// type text without a known rune are sent without one.
+	inputEventText inputEventKind = iota
+
+	// Bound key that does not type text.
+	inputEventKey
+
+	inputEventClickLeft
+	inputEventClickRight
+	inputEventWheel
+)
+
+// inputEvent is a single input received during a tick.
+type inputEvent struct {
+	kind inputEventKind
+
+	combo   keyCombo
+	hasKey  bool
+	r       rune
+	hasRune bool
+
+	pos image.Point // cursor position for mouse events
+	up  bool        // wheel direction
+
+	released int // tick the key was released at while ordering, -1 if held
+}
+
+// orderTimeout is the number of ticks to wait for the keys of a tick to be
+// released before dispatching its events.
+const orderTimeout = 8
+
+// inputQueue orders the events of ticks mixing typed text with other keys.
+// Ebiten only reports which keys were pressed during a tick, not in which
+// order, so these events are held until their keys are released: keys are
+// usually released in the order they were pressed.
+type inputQueue struct {
+	batch []inputEvent // events of the tick being ordered
+	later []inputEvent // events of the following ticks
+	ticks int          // since the batch was polled
+}
+
+// next returns the events to dispatch in order, events is what was polled
+// during the current tick.
+func (q *inputQueue) next(events []inputEvent, isPressed func(ebiten.Key) bool) []inputEvent {
+	if q.batch == nil {
+		if !isAmbiguous(events) {
+			return events
+		}
+
+		q.batch, q.ticks = events, 0
+		for k := range q.batch {
+			q.batch[k].released = -1
+		}
+		return nil
+	}
+
+	q.later = append(q.later, events...)
+	q.ticks++
+
+	held := false
+	for k := range q.batch {
+		event := &q.batch[k]
+		if !event.hasKey || event.released >= 0 {
+			continue
+		}
+
+		if isPressed(event.combo.key) && q.ticks < orderTimeout {
+			held = true
+			continue
+		}
+		event.released = q.ticks
+	}
+	if held {
+		return nil
+	}
+
+	ret := append(orderBatch(q.batch), q.later...)
+	q.batch, q.later = nil, nil
+	return ret
+}
+
+// isAmbiguous returns true if the events of a tick mix typed runes with keys
+// that do not type text, their order is then unknown.
+func isAmbiguous(events []inputEvent) bool {
+	var runes, keys bool
+	for _, v := range events {
+		switch {
+		case v.kind == inputEventText && v.hasRune:
+			runes = true
+		case v.hasKey:
+			keys = true
+		}
+	}
+
+	return runes && keys
+}
+
+// orderBatch inserts the keys that do not type text between the runes of the
+// same tick: a key goes before the first rune whose key was released after
+// it. Runes keep their order, runes typed by an unknown key follow the
+// previous one, and the mouse comes last.
+func orderBatch(events []inputEvent) []inputEvent {
+	var runes, keys, mouse []inputEvent
+	for _, v := range events {
+		switch {
+		case v.kind == inputEventText && v.hasRune:
+			runes = append(runes, v)
+		case v.hasKey:
+			keys = append(keys, v)
+		default:
+			mouse = append(mouse, v)
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].released < keys[j].released
+	})
+
+	ret := make([]inputEvent, 0, len(events))
+	released := -1
+	for _, r := range runes {
+		if r.hasKey {
+			released = r.released
+		}
+
+		for len(keys) > 0 && keys[0].released < released {
+			ret = append(ret, keys[0])
+			keys = keys[1:]
+		}
+		ret = append(ret, r)
+	}
+
+	ret = append(ret, keys...)
+	return append(ret, mouse...)
+}
+
+// pollEvents returns every input received during the current tick. Text comes
+// first in the order it was typed, then keys that do not type text (Enter,
+// Backspace, etc.), then the mouse. See inputQueue for ordering them.
+func (app *App) pollEvents() []inputEvent {
+	ctrl := ebiten.IsKeyPressed(ebiten.KeyControl)
+	shift := ebiten.IsKeyPressed(ebiten.KeyShift)
+	alt := ebiten.IsKeyPressed(ebiten.KeyAlt)
+
+	var textKeys []keyCombo
+	var keys []inputEvent
+	for k := ebiten.Key(0); k <= ebiten.KeyMax; k++ {
+		if k == ebiten.KeyControl || k == ebiten.KeyShift || k == ebiten.KeyAlt {
+			continue
+		}
+		if !inpututil.IsKeyJustPressed(k) {
+			continue
+		}
+
+		combo := keyCombo{key: k, ctrl: ctrl, shift: shift, alt: alt}
+		if combo.typesText() {
+			textKeys = append(textKeys, combo)
+		} else if app.isBound(combo) {
+			keys = append(keys, inputEvent{kind: inputEventKey, combo: combo, hasKey: true})
+		}
+	}
+
+	runes := ebiten.InputChars()
+	events := make([]inputEvent, 0, len(runes)+len(textKeys)+len(keys)+3)
+	for _, r := range runes {
+		event := inputEvent{kind: inputEventText, r: r, hasRune: true}
+		for k, combo := range textKeys {
+			if combo.types(r) {
+				event.combo, event.hasKey = combo, true
+				textKeys = append(textKeys[:k], textKeys[k+1:]...)
+				break
+			}
+		}
+		events = append(events, event)
+	}
+
+	for _, combo := range textKeys {
+		if app.isBound(combo) {
+			events = append(events, inputEvent{kind: inputEventText, combo: combo, hasKey: true})
+		}
+	}
+	events = append(events, keys...)
+
+	x, y := ebiten.CursorPosition()
+	pos := image.Point{x, y}
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		events = append(events, inputEvent{kind: inputEventClickLeft, pos: pos})
+	}
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight) {
+		events = append(events, inputEvent{kind: inputEventClickRight, pos: pos})
+	}
+	if _, wheel := ebiten.Wheel(); wheel != 0 {
+		events = append(events, inputEvent{kind: inputEventWheel, pos: pos, up: wheel > 0})
+	}
+
+	return events
+}
+
+// dispatch sends an input event to the tracker and timer.
+func (app *App) dispatch(event inputEvent) error {
+	switch event.kind {
+	case inputEventText:
+		// Keys are bound to actions, unless they are needed to type text.
+		if event.hasKey && !(app.tracker.EatInput() && event.hasRune) {
+			if action, ok := app.keyAction(event.combo); ok {
+				return app.do(action)
+			}
+		}
+
+		if event.hasRune {
+			return app.input(event.r)
+		}
+
+	case inputEventKey:
+		if action, ok := app.keyAction(event.combo); ok {
+			return app.do(action)
+		}
+
+	case inputEventClickLeft:
+		app.tracker.ClickLeft(event.pos.X, event.pos.Y)
+	case inputEventClickRight:
+		app.tracker.ClickRight(event.pos.X, event.pos.Y)
+	case inputEventWheel:
+		app.tracker.Wheel(event.pos.X, event.pos.Y, event.up)
+	}
+
+	return nil
+}
+
+// isBound returns true if the key combination is bound to an action in any
+// input context.
+func (app *App) isBound(combo keyCombo) bool {
+	_, ok := app.config.keys[combo]
+	_, okText := app.config.textKeys[combo]
+	return ok || okText
+}
+
+// keyAction returns the action bound to a key combination, text keys take
+// precedence while the tracker is eating input.
+func (app *App) keyAction(combo keyCombo) (string, bool) {
+	if app.tracker.EatInput() {
+		if action, ok := app.config.textKeys[combo]; ok {
+			return action, true
+		}
+	}
+
+	action, ok := app.config.keys[combo]
+	return action, ok
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+func TestInputQueue(t *testing.T) {
+	typed := func(r rune, key ebiten.Key) inputEvent {
+		return inputEvent{kind: inputEventText, r: r, hasRune: true, combo: keyCombo{key: key}, hasKey: true}
+	}
+	key := func(key ebiten.Key) inputEvent {
+		return inputEvent{kind: inputEventKey, combo: keyCombo{key: key}, hasKey: true}
+	}
+	click := inputEvent{kind: inputEventClickLeft}
+
+	a, b := typed('a', ebiten.KeyA), typed('b', ebiten.KeyB)
+	enter, backspace := key(ebiten.KeyEnter), key(ebiten.KeyBackspace)
+
+	for _, c := range []struct {
+		name     string
+		ticks    [][]inputEvent
+		released [][]ebiten.Key // keys released at the end of each tick
+		want     []inputEvent
+	}{
+		{
+			name:  "text only",
+			ticks: [][]inputEvent{{a, b}},
+			want:  []inputEvent{a, b},
+		},
+		{
+			name:     "key released first",
+			ticks:    [][]inputEvent{{a, enter}, {}},
+			released: [][]ebiten.Key{{ebiten.KeyEnter}, {ebiten.KeyA}},
+			want:     []inputEvent{enter, a},
+		},
+		{
+			name:     "key released last",
+			ticks:    [][]inputEvent{{a, b, backspace}, {}, {}},
+			released: [][]ebiten.Key{{ebiten.KeyA}, {ebiten.KeyB}, {ebiten.KeyBackspace}},
+			want:     []inputEvent{a, b, backspace},
+		},
+		{
+			name:     "key released between runes",
+			ticks:    [][]inputEvent{{a, b, backspace, click}, {}, {}},
+			released: [][]ebiten.Key{{ebiten.KeyA}, {ebiten.KeyBackspace}, {ebiten.KeyB}},
+			want:     []inputEvent{a, backspace, b, click},
+		},
+		{
+			name:     "later ticks follow",
+			ticks:    [][]inputEvent{{a, enter}, {b}, {}},
+			released: [][]ebiten.Key{{ebiten.KeyA}, {}, {ebiten.KeyEnter}},
+			want:     []inputEvent{a, enter, b},
+		},
+	} {
+		var (
+			queue   inputQueue
+			got     []inputEvent
+			pressed = make(map[ebiten.Key]bool)
+		)
+		for k, events := range c.ticks {
+			for _, v := range events {
+				if v.hasKey {
+					pressed[v.combo.key] = true
+				}
+			}
+
+			isPressed := func(key ebiten.Key) bool { return pressed[key] }
+			got = append(got, queue.next(append([]inputEvent(nil), events...), isPressed)...)
+
+			if k < len(c.released) {
+				for _, v := range c.released[k] {
+					pressed[v] = false
+				}
+			}
+		}
+
+		// Every key held is released after the timeout.
+		for k := 0; queue.batch != nil && k < orderTimeout; k++ {
+			got = append(got, queue.next(nil, func(ebiten.Key) bool { return true })...)
+		}
+
+		for k := range got {
+			got[k].released = 0
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s:\ngot  %+v\nwant %+v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"ivan/timer"
+	"ivan/tracker"
+	"log"
+	"os"
+	"time"
+)
+
+const (
+	journalName         = "journal.jsonl"
+	previousJournalName = "journal.previous.jsonl"
+)
+
+type timerAction string
+
+const (
+	timerActionToggle timerAction = "toggle"
+	timerActionReset  timerAction = "reset"
+)
+
+// journalEntry is a single line of the journal, either a tracker or timer
+// action. Time is the wall clock time the action happened at, Elapsed the
+// timer value at that time which can go back when the timer is reset.
+type journalEntry struct {
+	Time    time.Time
+	Elapsed time.Duration
+	Tracker *tracker.Event `json:",omitempty"`
+	Timer   timerAction    `json:",omitempty"`
+
+	// Timer state after the timer action, restored on replay.
+	TimerState *timer.State `json:",omitempty"`
+}
+
+// journal records every action of the current session as JSON lines.
+type journal struct {
+	path  string
+	f     *os.File
+	enc   *json.Encoder
+	timer *timer.Timer
+}
+
+func openJournal(path string, timer *timer.Timer) (*journal, error) {
+	j := &journal{path: path, timer: timer}
+	if err := j.open(); err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+func (j *journal) open() error {
+	f, err := os.OpenFile(j.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	j.f = f
+	j.enc = json.NewEncoder(f)
+	return nil
+}
+
+func readJournal(path string) ([]journalEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ret []journalEntry
+	dec := json.NewDecoder(f)
+	for {
+		var entry journalEntry
+		if err := dec.Decode(&entry); err != nil {
+			if err == io.EOF {
+				return ret, nil
+			}
+			return ret, err
+		}
+
+		ret = append(ret, entry)
+	}
+}
+
+// Record implements tracker.Journal.
+func (j *journal) Record(event tracker.Event) {
+	j.write(j.entry(journalEntry{Tracker: &event}))
+}
+
+func (j *journal) recordTimer(action timerAction) {
+	state := j.timer.State()
+	j.write(j.entry(journalEntry{Timer: action, TimerState: &state}))
+}
+
+// entry timestamps a new journal entry.
+func (j *journal) entry(entry journalEntry) journalEntry {
+	entry.Time = time.Now()
+	entry.Elapsed = j.timer.Elapsed()
+	return entry
+}
+
+func (j *journal) write(entry journalEntry) {
+	if err := j.enc.Encode(entry); err != nil {
+		log.Printf("warning: unable to write journal: %s", err)
+	}
+}
+
+// truncate discards all recorded entries, to be called when starting a new
+// session.
+func (j *journal) truncate() {
+	if err := j.f.Truncate(0); err != nil {
+		log.Printf("warning: unable to truncate journal: %s", err)
+	}
+}
+
+// rotate moves the current journal to previousPath and starts a new one.
+func (j *journal) rotate(previousPath string) {
+	j.f.Close()
+	if err := os.Rename(j.path, previousPath); err != nil {
+		log.Printf("warning: unable to rotate journal: %s", err)
+	}
+
+	if err := j.open(); err != nil {
+		log.Printf("warning: unable to open journal: %s", err)
+	}
+}
+
+// swap exchanges the current journal with the one at previousPath.
+func (j *journal) swap(previousPath string) {
+	j.f.Close()
+
+	tmpPath := j.path + ".tmp"
+	for _, v := range [][2]string{
+		{j.path, tmpPath},
+		{previousPath, j.path},
+		{tmpPath, previousPath},
+	} {
+		if err := os.Rename(v[0], v[1]); err != nil && !os.IsNotExist(err) {
+			log.Printf("warning: unable to swap journals: %s", err)
+		}
+	}
+
+	if err := j.open(); err != nil {
+		log.Printf("warning: unable to open journal: %s", err)
+	}
+}
+
+// replay rebuilds the tracker and timer states from a journal then uses its
+// entries as the start of the current journal.
+func (app *App) replay(path string) error {
+	entries, err := readJournal(path)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range entries {
+		switch {
+		case v.Tracker != nil:
+			if err := app.tracker.Replay(*v.Tracker); err != nil {
+				log.Printf("warning: replay at %s: %s", v.Time.Format(time.RFC3339), err)
+			}
+		case v.TimerState != nil:
+			app.timer.SetState(*v.TimerState)
+		}
+	}
+
+	app.journal.truncate()
+	for _, v := range entries {
+		app.journal.write(v)
+	}
+
+	return nil
+}
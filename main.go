@@ -2,9 +2,6 @@ package main
 
 import (
 	"log"
-	"os"
-	"path"
-	"path/filepath"
 	"runtime"
 
 	_ "image/png"
@@ -19,7 +16,10 @@ var Version = "unknown"
 func main() {
 	log.Printf("ivan %s\n", Version)
 
-	chdirToExecutableDir()
+	opts, err := parseOptions()
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	ebiten.SetWindowTitle("Ivan")
 	ebiten.SetRunnableOnUnfocused(true)
@@ -29,7 +29,7 @@ func main() {
 		ebiten.SetWindowDecorated(false)
 	}
 
-	ivan, err := NewApp()
+	ivan, err := NewApp(opts)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -38,20 +38,3 @@ func main() {
 		log.Fatal(err)
 	}
 }
-
-func chdirToExecutableDir() {
-	exec, err := os.Executable()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	solved, err := filepath.EvalSymlinks(exec)
-	if err != nil {
-		log.Printf("warning: %s", err)
-		solved = exec
-	}
-
-	if err := os.Chdir(path.Dir(solved)); err != nil {
-		log.Fatal(err)
-	}
-}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// options holds the paths and settings given on the command line or through
+// environment variables.
+type options struct {
+	configPath  string
+	assetsDir   string
+	sessionPath string // journal and previous session are stored alongside
+	replayPath  string
+	hintProfile string // overrides the config HintProfile
+
+	windowPos    image.Point
+	hasWindowPos bool
+}
+
+func parseOptions() (options, error) {
+	var (
+		opts      options
+		windowPos string
+	)
+
+	flag.StringVar(
+		&opts.configPath, "config", os.Getenv("IVAN_CONFIG"),
+		"configuration file, defaults to config.json in the assets directory (env IVAN_CONFIG)",
+	)
+	flag.StringVar(
+		&opts.assetsDir, "assets", os.Getenv("IVAN_ASSETS"),
+		"assets directory, defaults to the assets directory next to the executable (env IVAN_ASSETS)",
+	)
+	flag.StringVar(
+		&opts.sessionPath, "session", os.Getenv("IVAN_SESSION"),
+		"session file, defaults to session.json in the ivan user configuration directory (env IVAN_SESSION)",
+	)
+	flag.StringVar(
+		&windowPos, "window-pos", os.Getenv("IVAN_WINDOW_POS"),
+		"window position as X,Y, defaults to the top right of a 1080p screen (env IVAN_WINDOW_POS)",
+	)
+	flag.StringVar(
+		&opts.hintProfile, "hint-profile", os.Getenv("IVAN_HINT_PROFILE"),
+		"hint distribution profile, overrides HintProfile from the configuration file (env IVAN_HINT_PROFILE)",
+	)
+	flag.StringVar(
+		&opts.replayPath, "replay", "",
+		"rebuild the tracker state from the given journal file",
+	)
+	flag.Parse()
+
+	if windowPos != "" {
+		if _, err := fmt.Sscanf(windowPos, "%d,%d", &opts.windowPos.X, &opts.windowPos.Y); err != nil {
+			return options{}, fmt.Errorf("invalid window position %q, expected X,Y: %w", windowPos, err)
+		}
+		opts.hasWindowPos = true
+	}
+
+	// Resolve user-given paths so they do not depend on the working
+	// directory later on.
+	for _, v := range []*string{
+		&opts.configPath,
+		&opts.assetsDir,
+		&opts.sessionPath,
+		&opts.replayPath,
+	} {
+		if *v == "" {
+			continue
+		}
+
+		abs, err := filepath.Abs(*v)
+		if err != nil {
+			return options{}, err
+		}
+		*v = abs
+	}
+
+	if opts.assetsDir == "" {
+		opts.assetsDir = filepath.Join(executableDir(), "assets")
+	}
+
+	if opts.configPath == "" {
+		opts.configPath = filepath.Join(opts.assetsDir, "config.json")
+	}
+
+	// The journal and ranking are stored alongside the session, in the user
+	// configuration directory so the executable directory can be read-only.
+	if opts.sessionPath == "" {
+		dir, err := stateDir()
+		if err != nil {
+			return options{}, err
+		}
+		opts.sessionPath = filepath.Join(dir, "session.json")
+	}
+
+	return opts, nil
+}
+
+// stateDir returns the directory the session, journal and ranking are stored
+// in by default, creating it if needed.
+func stateDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to find the user configuration directory, use -session: %w", err)
+	}
+
+	dir = filepath.Join(dir, "ivan")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	return dir, nil
+}
+
+// statePath returns the path of a file stored alongside the session file.
+func (opts options) statePath(name string) string {
+	return filepath.Join(filepath.Dir(opts.sessionPath), name)
+}
+
+// executableDir returns the directory of the executable, symlinks resolved.
+func executableDir() string {
+	exec, err := os.Executable()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	solved, err := filepath.EvalSymlinks(exec)
+	if err != nil {
+		log.Printf("warning: %s", err)
+		solved = exec
+	}
+
+	return filepath.Dir(solved)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"ivan/tracker"
+	"log"
+	"os"
+)
+
+const rankingName = "ranking.json" // locations picked for each hint input
+
+// loadRanking returns the location ranking stored at the given path, or nil if
+// there is none.
+func loadRanking(path string) (tracker.Ranking, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var ret tracker.Ranking
+	dec := json.NewDecoder(f)
+	if err := dec.Decode(&ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// saveRanking writes the location ranking to disk if it changed since the last
+// save. It is kept across sessions and resets.
+func (app *App) saveRanking() {
+	data, err := json.Marshal(app.tracker.Ranking())
+	if err != nil {
+		log.Printf("warning: unable to serialize ranking: %s", err)
+		return
+	}
+
+	if bytes.Equal(data, app.lastSavedRanking) {
+		return
+	}
+
+	if err := writeFileAtomic(app.opts.statePath(rankingName), data); err != nil {
+		log.Printf("warning: unable to save ranking: %s", err)
+		return
+	}
+
+	app.lastSavedRanking = data
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io/ioutil"
+	"ivan/timer"
+	"ivan/tracker"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+)
+
+const (
+	previousSessionName = "session.previous.json" // session discarded by the last reset
+	autosaveInterval    = time.Second
+)
+
+// session is everything needed to resume tracking after a crash.
+type session struct {
+	Tracker tracker.State
+	Timer   timer.State
+}
+
+// loadSession returns the session stored at the given path, or nil if there is
+// none.
+func loadSession(path string) (*session, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var ret session
+	dec := json.NewDecoder(f)
+	if err := dec.Decode(&ret); err != nil {
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+// writeFileAtomic writes data to a temporary file then renames it over path so
+// a crash never leaves a truncated file behind.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+func (app *App) session() session {
+	return session{
+		Tracker: app.tracker.State(),
+		Timer:   app.timer.State(),
+	}
+}
+
+// autosave writes the session and location ranking to disk at most once every
+// autosaveInterval.
+func (app *App) autosave() {
+	if time.Now().Before(app.nextAutosave) {
+		return
+	}
+
+	app.nextAutosave = time.Now().Add(autosaveInterval)
+	app.saveSession()
+	app.saveRanking()
+}
+
+// saveSession writes the session to disk if it changed since the last save.
+func (app *App) saveSession() {
+	data, err := json.Marshal(app.session())
+	if err != nil {
+		log.Printf("warning: unable to serialize session: %s", err)
+		return
+	}
+
+	if bytes.Equal(data, app.lastSavedSession) {
+		return
+	}
+
+	if err := writeFileAtomic(app.opts.sessionPath, data); err != nil {
+		log.Printf("warning: unable to save session: %s", err)
+		return
+	}
+
+	app.lastSavedSession = data
+}
+
+// offerSession loads the previous session and asks the user whether to restore
+// it if it differs from the current (fresh) one.
+func (app *App) offerSession() {
+	saved, err := loadSession(app.opts.sessionPath)
+	if err != nil {
+		log.Printf("warning: unable to load session: %s", err)
+		app.journal.truncate()
+		return
+	}
+
+	fresh, err := json.Marshal(app.session())
+	if err != nil {
+		log.Printf("warning: unable to serialize session: %s", err)
+		return
+	}
+	app.lastSavedSession = fresh
+
+	if saved == nil {
+		app.journal.truncate()
+		return
+	}
+
+	data, err := json.Marshal(saved)
+	if err != nil || bytes.Equal(data, fresh) {
+		app.journal.truncate()
+		return
+	}
+
+	app.pendingSession = saved
+}
+
+// resetSession starts a new session with the given config, the current
+// session is kept aside so restorePreviousSession can bring it back.
+func (app *App) resetSession(config config) {
+	current := app.session()
+	app.setPreviousSession(&current)
+
+	// Rotate first so the reset is recorded at the start of the new journal.
+	app.journal.rotate(app.opts.statePath(previousJournalName))
+	app.setConfig(config)
+	app.tracker.Reset(config.trackerConfig())
+}
+
+// nextHintProfile resets the session using the hint profile following the
+// current one in alphabetical order, it is kept on further resets and
+// configuration reloads.
+func (app *App) nextHintProfile() {
+	names := make([]string, 0, len(app.config.HintProfiles))
+	for k := range app.config.HintProfiles {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return
+	}
+
+	next := names[0]
+	for k, v := range names {
+		if v == app.tracker.HintProfile() {
+			next = names[(k+1)%len(names)]
+			break
+		}
+	}
+
+	opts := app.opts
+	opts.hintProfile = next
+	config, err := loadConfig(opts)
+	if err != nil {
+		log.Printf("error: %s", err)
+		return
+	}
+
+	log.Printf("using hint profile %s", next)
+	app.opts = opts
+	app.resetSession(config)
+}
+
+// restorePreviousSession swaps the current session with the one discarded by
+// the last reset, restoring twice is a no-op.
+func (app *App) restorePreviousSession() {
+	if app.previousSession == nil {
+		log.Printf("no previous session to restore")
+		return
+	}
+
+	previous := app.previousSession
+	current := app.session()
+	app.setPreviousSession(&current)
+
+	app.tracker.SetState(previous.Tracker)
+	app.timer.SetState(previous.Timer)
+	app.journal.swap(app.opts.statePath(previousJournalName))
+}
+
+// syncHintProfile keeps the hint profile of a restored session or undone reset
+// on further resets and configuration reloads.
+func (app *App) syncHintProfile() {
+	profile := app.tracker.HintProfile()
+	if profile == app.config.HintProfile {
+		return
+	}
+
+	if profile == "" {
+		log.Printf("using no hint profile")
+	} else {
+		log.Printf("using hint profile %s", profile)
+	}
+	app.opts.hintProfile = profile
+	app.config.HintProfile = profile
+}
+
+func (app *App) setPreviousSession(s *session) {
+	app.previousSession = s
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		log.Printf("warning: unable to serialize session: %s", err)
+		return
+	}
+
+	if err := writeFileAtomic(app.opts.statePath(previousSessionName), data); err != nil {
+		log.Printf("warning: unable to save previous session: %s", err)
+	}
+}
+
+// updateRestorePrompt restores the pending session on the keys bound to
+// submit and discards it on the keys bound to cancel or quit.
+func (app *App) updateRestorePrompt() {
+	for _, event := range app.pollEvents() {
+		if !event.hasKey {
+			continue
+		}
+
+		switch action, _ := app.keyAction(event.combo); action {
+		case string(tracker.ActionSubmit):
+			app.tracker.SetState(app.pendingSession.Tracker)
+			app.timer.SetState(app.pendingSession.Timer)
+			app.pendingSession = nil
+			app.syncHintProfile()
+			return
+
+		case string(tracker.ActionCancel), actionQuit:
+			app.setPreviousSession(app.pendingSession)
+			app.pendingSession = nil
+			app.lastSavedSession = nil // overwrite the discarded session on next save
+			app.journal.rotate(app.opts.statePath(previousJournalName))
+			return
+		}
+	}
+}
+
+func (app *App) drawRestorePrompt(screen *ebiten.Image) {
+	w, h := screen.Size()
+	ebitenutil.DrawRect(screen, 0, 0, float64(w), float64(h), color.RGBA{0, 0, 0, 0xC0})
+	ebitenutil.DebugPrintAt(
+		screen,
+		fmt.Sprintf(
+			"A previous session was found.\n\n%s: restore it\n%s: discard it",
+			app.boundKeys(string(tracker.ActionSubmit)),
+			app.boundKeys(string(tracker.ActionCancel), actionQuit),
+		),
+		10, 10,
+	)
+}
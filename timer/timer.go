@@ -111,3 +111,45 @@ func (timer *Timer) Reset() {
 func (timer *Timer) IsRunning() bool {
 	return timer.state != stateInitial
 }
+
+// State is the serializable state of a Timer.
+type State struct {
+	StartedAt, PausedAt time.Time
+	Status              timerState
+}
+
+// State returns the current timer state.
+func (timer *Timer) State() State {
+	return State{
+		StartedAt: timer.startedAt,
+		PausedAt:  timer.pausedAt,
+		Status:    timer.state,
+	}
+}
+
+// SetState restores a previous timer state, a running timer resumes against
+// its original start time.
+func (timer *Timer) SetState(state State) {
+	if state.Status < stateInitial || state.Status > statePaused {
+		state.Status = stateInitial
+	}
+
+	timer.startedAt = state.StartedAt
+	timer.pausedAt = state.PausedAt
+	timer.state = state.Status
+}
+
+// Elapsed returns the time since the timer was started, or 0 if it was not.
+func (timer *Timer) Elapsed() time.Duration {
+	if timer.state == stateInitial {
+		return 0
+	}
+
+	return time.Since(timer.startedAt)
+}
+
+// SetDimensions moves and resizes the timer.
+func (timer *Timer) SetDimensions(dimensions image.Rectangle) {
+	timer.pos = dimensions.Min
+	timer.size = dimensions.Size()
+}
@@ -0,0 +1,128 @@
+package tracker
+
+import (
+	"fmt"
+)
+
+// Action is the name of a tracker action that can be bound to a rune or key.
+type Action string
+
+const (
+	ActionStartItemInput     Action = "startItemInput"
+	ActionDowngradeNext      Action = "downgradeNext"
+	ActionSubmit             Action = "submit"
+	ActionCancel             Action = "cancel"
+	ActionUndo               Action = "undo"
+	ActionRedo               Action = "redo"
+	ActionStartHintSelection Action = "startHintSelection"
+	ActionStartItemSearch    Action = "startItemSearch"
+	ActionStartValueInput    Action = "startValueInput"
+	ActionStartTempleInput   Action = "startTempleInput"
+	ActionStartDungeonInput  Action = "startDungeonInput"
+
+	// Keypad directions, used to select zones and items, and hints.
+	ActionTopLeft     Action = "topLeft"
+	ActionTop         Action = "top"
+	ActionTopRight    Action = "topRight"
+	ActionLeft        Action = "left"
+	ActionMiddle      Action = "middle"
+	ActionRight       Action = "right"
+	ActionBottomLeft  Action = "bottomLeft"
+	ActionBottom      Action = "bottom"
+	ActionBottomRight Action = "bottomRight"
+)
+
+// nolint:gochecknoglobals
+var actionsByName = map[Action]action{
+	ActionStartItemInput:     actionStartItemInput,
+	ActionDowngradeNext:      actionDowngradeNext,
+	ActionSubmit:             actionSubmit,
+	ActionCancel:             actionCancel,
+	ActionUndo:               actionUndo,
+	ActionRedo:               actionRedo,
+	ActionStartHintSelection: actionStartHintSelection,
+	ActionStartItemSearch:    actionStartItemSearch,
+	ActionStartValueInput:    actionStartValueInput,
+	ActionStartTempleInput:   actionStartTempleInput,
+	ActionStartDungeonInput:  actionStartDungeonInput,
+
+	ActionTopLeft:     actionTopLeft,
+	ActionTop:         actionTop,
+	ActionTopRight:    actionTopRight,
+	ActionLeft:        actionLeft,
+	ActionMiddle:      actionMiddle,
+	ActionRight:       actionRight,
+	ActionBottomLeft:  actionBottomLeft,
+	ActionBottom:      actionBottom,
+	ActionBottomRight: actionBottomRight,
+}
+
+// IsAction returns true if the given name is a tracker action.
+func IsAction(name Action) bool {
+	_, ok := actionsByName[name]
+	return ok
+}
+
+// DefaultRunes returns the text input bindings used when the configuration
+// does not define any. As they work on text they follow the keyboard layout
+// but can't distinguish the main keyboard from the keypad.
+func DefaultRunes() map[string]Action {
+	return map[string]Action{
+		"0": ActionStartItemInput,
+		".": ActionDowngradeNext,
+		"-": ActionUndo,
+		"+": ActionRedo,
+		"e": ActionStartHintSelection,
+		"i": ActionStartItemSearch,
+		"*": ActionStartValueInput,
+		"/": ActionStartTempleInput,
+		"k": ActionStartDungeonInput,
+
+		"7": ActionTopLeft,
+		"8": ActionTop,
+		"9": ActionTopRight,
+		"4": ActionLeft,
+		"5": ActionMiddle,
+		"6": ActionRight,
+		"1": ActionBottomLeft,
+		"2": ActionBottom,
+		"3": ActionBottomRight,
+	}
+}
+
+// parseRunes returns the rune bindings of the configuration.
+func parseRunes(runes map[string]Action) (map[rune]action, error) {
+	ret := make(map[rune]action, len(runes))
+	for k, v := range runes {
+		r := []rune(k)
+		if len(r) != 1 {
+			return nil, fmt.Errorf("rune binding must be a single character, got %q", k)
+		}
+
+		a, ok := actionsByName[v]
+		if !ok {
+			return nil, fmt.Errorf("unknown action %q", v)
+		}
+
+		ret[r[0]] = a
+	}
+
+	return ret, nil
+}
+
+// Trigger applies a tracker action bound to a key.
+func (tracker *Tracker) Trigger(name Action) {
+	a, ok := actionsByName[name]
+	if !ok {
+		return
+	}
+
+	switch a {
+	case actionSubmit:
+		tracker.Submit()
+	case actionCancel:
+		tracker.Cancel()
+	default:
+		tracker.inputAction(a)
+	}
+}
@@ -0,0 +1,90 @@
+package tracker
+
+import (
+	"fmt"
+)
+
+// LocationKind restricts the locations a hint category accepts.
+type LocationKind string
+
+const (
+	LocationKindAny       LocationKind = ""
+	LocationKindDungeon   LocationKind = "dungeon"
+	LocationKindOverworld LocationKind = "overworld"
+)
+
+// isDungeon returns true if the location is listed as a dungeon.
+func (tracker *Tracker) isDungeon(location string) bool {
+	return contains(tracker.dungeonLocations, location)
+}
+
+func (tracker *Tracker) isLocation(location string) bool {
+	return contains(tracker.locations, location)
+}
+
+// hintConflict returns a warning if adding h to the category would conflict
+// with existing hints, or an empty string. exclude is the index of the hint
+// being edited, or -1 when adding a new one.
+func (tracker *Tracker) hintConflict(catIndex, slot int, h hint, exclude int) string {
+	if h.Text == "" {
+		return ""
+	}
+
+	cat := &tracker.hintCategories[catIndex]
+	if cat.isSlotted() && exclude < 0 && slot >= 0 && slot < len(cat.hints) && cat.hints[slot].Text != "" {
+		return fmt.Sprintf("%s already set to %s", cat.Slots[slot].Name, cat.hints[slot].String())
+	}
+
+	for k, v := range cat.hints {
+		if k != exclude && v.Text != "" && v.Text == h.Text && v.Item == h.Item {
+			return fmt.Sprintf("%s already hinted as %s", h.String(), cat.Name)
+		}
+	}
+
+	if cat.Match != HintMatchLocation || !tracker.isLocation(h.Text) {
+		return ""
+	}
+
+	switch cat.LocationKind {
+	case LocationKindDungeon:
+		if !tracker.isDungeon(h.Text) {
+			return fmt.Sprintf("%s is not a dungeon", h.Text)
+		}
+	case LocationKindOverworld:
+		if tracker.isDungeon(h.Text) {
+			return fmt.Sprintf("%s is a dungeon", h.Text)
+		}
+	}
+
+	for k := range tracker.hintCategories {
+		other := &tracker.hintCategories[k]
+		if k == catIndex || other.Match != HintMatchLocation {
+			continue
+		}
+
+		for _, v := range other.hints {
+			if v.Text == h.Text {
+				return fmt.Sprintf("%s already hinted as %s", h.Text, other.Name)
+			}
+		}
+	}
+
+	return ""
+}
+
+// confirmHint returns true if h can be added, otherwise it displays the
+// conflict on the input line and returns true on the next submit of the same
+// input.
+func (tracker *Tracker) confirmHint(catIndex, slot int, h hint, exclude int) bool {
+	warning := tracker.hintConflict(catIndex, slot, h, exclude)
+	if warning == "" && tracker.textInputAmbiguous() {
+		warning = "several places match, Tab to choose"
+	}
+	if warning == "" || warning == tracker.input.warning {
+		tracker.input.warning = ""
+		return true
+	}
+
+	tracker.input.warning = warning
+	return false
+}
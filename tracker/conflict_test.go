@@ -0,0 +1,34 @@
+package tracker
+
+import "testing"
+
+func TestHintConflict(t *testing.T) {
+	tracker := newTestTracker("")
+	tracker.addHint(0, -1, hint{Text: "Lost Woods"})
+	tracker.addHint(2, 0, hint{Text: "Skull Mask", Item: "Bow"})
+
+	for _, c := range []struct {
+		name     string
+		category int
+		slot     int
+		hint     hint
+		exclude  int
+		want     string
+	}{
+		{"no conflict", 0, -1, hint{Text: "Forest Temple"}, -1, ""},
+		{"empty text", 0, -1, hint{}, -1, ""},
+		{"duplicate", 0, -1, hint{Text: "Lost Woods"}, -1, "Lost Woods already hinted as WotH"},
+		{"editing itself", 0, -1, hint{Text: "Lost Woods"}, 0, ""},
+		{"other category", 1, -1, hint{Text: "Lost Woods"}, -1, "Lost Woods already hinted as WotH"},
+		{"wrong location kind", 1, -1, hint{Text: "Ice Cavern"}, -1, "Ice Cavern is a dungeon"},
+		{"unknown location", 1, -1, hint{Text: "Somewhere"}, -1, ""},
+		{"slot set", 2, 0, hint{Text: "Skull Mask", Item: "Hookshot"}, -1, "Skull Mask already set to Skull Mask - Bow"},
+		{"empty slot", 2, 1, hint{Text: "Biggoron", Item: "Hookshot"}, -1, ""},
+		{"empty slots are not duplicates", 2, 1, hint{Text: "Biggoron"}, -1, ""},
+		{"replacing a slot", 2, 0, hint{Text: "Skull Mask", Item: "Hookshot"}, 0, ""},
+	} {
+		if got := tracker.hintConflict(c.category, c.slot, c.hint, c.exclude); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package tracker
+
+import (
+	"strings"
+)
+
+// Dungeon is a place a dungeon reward (eg. a stone or medallion) can be found
+// in.
+type Dungeon struct {
+	Name  string
+	Label string `json:",omitempty"` // displayed on the reward, defaults to Name
+
+	// Unique dungeons hold a single reward, they are skipped when assigning
+	// rewards once they already hold one.
+	Unique bool `json:",omitempty"`
+}
+
+// DefaultDungeons returns the dungeons used when the configuration does not
+// define any, ie. the vanilla location of every reward.
+func DefaultDungeons() []Dungeon {
+	return []Dungeon{
+		{Name: "Free"},
+		{Name: "Deku Tree", Label: "Deku", Unique: true},
+		{Name: "Dodongo's Cavern", Label: "DC", Unique: true},
+		{Name: "Jabu Jabu's Belly", Label: "Jabu", Unique: true},
+		{Name: "Forest Temple", Label: "Forest", Unique: true},
+		{Name: "Fire Temple", Label: "Fire", Unique: true},
+		{Name: "Water Temple", Label: "Water", Unique: true},
+		{Name: "Spirit Temple", Label: "Spirit", Unique: true},
+		{Name: "Shadow Temple", Label: "Shdw", Unique: true},
+	}
+}
+
+func (dungeon Dungeon) label() string {
+	if dungeon.Label == "" {
+		return dungeon.Name
+	}
+
+	return dungeon.Label
+}
+
+// getDungeonIndex returns the index of the dungeon having the given name or
+// label, or -1 if there is no such dungeon.
+func (tracker *Tracker) getDungeonIndex(str string) int {
+	if str == "" {
+		return -1
+	}
+
+	for k, v := range tracker.dungeons {
+		if strings.EqualFold(v.Name, str) || strings.EqualFold(v.label(), str) {
+			return k
+		}
+	}
+
+	return -1
+}
+
+// isDungeonReward returns true if the item accepts a dungeon.
+func (tracker *Tracker) isDungeonReward(itemIndex int) bool {
+	return contains(tracker.dungeonRewards, tracker.items[itemIndex].Name)
+}
+
+// isDungeonAvailable returns true if the dungeon can be assigned to the given
+// reward, ie. it is not unique or does not already hold another reward.
+func (tracker *Tracker) isDungeonAvailable(dungeonIndex, itemIndex int) bool {
+	if !tracker.dungeons[dungeonIndex].Unique {
+		return true
+	}
+
+	for k := range tracker.items {
+		if k != itemIndex && tracker.items[k].temple == tracker.dungeons[dungeonIndex].Name &&
+			tracker.isDungeonReward(k) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// cycleTemple assigns the next (or previous) available dungeon to a reward,
+// going through no dungeon at the end of the list.
+func (tracker *Tracker) cycleTemple(itemIndex int, up bool) {
+	step := 1
+	if !up {
+		step = -1
+	}
+
+	// -1 being no dungeon.
+	count := len(tracker.dungeons) + 1
+	index := tracker.getDungeonIndex(tracker.items[itemIndex].temple)
+	for k := 1; k < count; k++ {
+		next := ((index+1+k*step)%count+count)%count - 1
+		if next < 0 || tracker.isDungeonAvailable(next, itemIndex) {
+			index = next
+			break
+		}
+	}
+
+	tracker.setTemple(itemIndex, index)
+}
+
+// setTemple assigns a dungeon to a reward, -1 removes it.
+func (tracker *Tracker) setTemple(itemIndex, dungeonIndex int) {
+	var name string
+	if dungeonIndex >= 0 {
+		name = tracker.dungeons[dungeonIndex].Name
+	}
+
+	tracker.updateItem(itemIndex, func(item *Item) bool {
+		if item.temple == name {
+			return false
+		}
+
+		item.temple = name
+		return true
+	})
+}
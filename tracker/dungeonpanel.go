@@ -0,0 +1,455 @@
+package tracker
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"strconv"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+	"github.com/hajimehoshi/ebiten/text"
+)
+
+// DungeonLineHeight is the height of a dungeon in the dungeon panel.
+const DungeonLineHeight = 18
+
+const dungeonBaseline = 14
+
+// PanelDungeon is a dungeon listed in the dungeon panel.
+type PanelDungeon struct {
+	Name  string
+	Label string `json:",omitempty"` // displayed in the panel, defaults to Name
+
+	// Small keys in the vanilla and Master Quest variants.
+	Keys, MQKeys int `json:",omitempty"`
+
+	BossKey    bool `json:",omitempty"` // has a boss key
+	MapCompass bool `json:",omitempty"` // has a map and a compass
+}
+
+func (dungeon PanelDungeon) label() string {
+	if dungeon.Label == "" {
+		return dungeon.Name
+	}
+
+	return dungeon.Label
+}
+
+// maxKeys returns the number of small keys of the given variant.
+func (dungeon PanelDungeon) maxKeys(mq bool) int {
+	if mq {
+		return dungeon.MQKeys
+	}
+
+	return dungeon.Keys
+}
+
+// DungeonState holds what the user tracked in a dungeon, identified by its
+// name.
+type DungeonState struct {
+	Name      string
+	MQ        bool `json:",omitempty"`
+	Keys      int  `json:",omitempty"`
+	BossKey   bool `json:",omitempty"`
+	Map       bool `json:",omitempty"`
+	Compass   bool `json:",omitempty"`
+	Completed bool `json:",omitempty"`
+}
+
+type dungeonField int
+
+const (
+	dungeonFieldNone dungeonField = iota
+	dungeonFieldCompleted
+	dungeonFieldMQ
+	dungeonFieldKeys
+	dungeonFieldBossKey
+	dungeonFieldMap
+	dungeonFieldCompass
+)
+
+// dungeonColumns are the fields of a panel line in order with their share of
+// the panel width, the completion is toggled using the label.
+// nolint:gochecknoglobals
+var dungeonColumns = []struct {
+	field  dungeonField
+	weight int
+}{
+	{dungeonFieldCompleted, 8},
+	{dungeonFieldMQ, 2},
+	{dungeonFieldKeys, 3},
+	{dungeonFieldBossKey, 2},
+	{dungeonFieldMap, 2},
+	{dungeonFieldCompass, 2},
+}
+
+// dungeonKPOrder are the keypad numbers selecting the lines of a zone, from
+// top to bottom.
+// nolint:gochecknoglobals
+var dungeonKPOrder = []int{7, 8, 9, 4, 5, 6, 1, 2, 3}
+
+// has returns true if the dungeon has the given field.
+func (dungeon PanelDungeon) has(field dungeonField) bool {
+	switch field {
+	case dungeonFieldCompleted, dungeonFieldMQ:
+		return true
+	case dungeonFieldKeys:
+		return dungeon.Keys > 0 || dungeon.MQKeys > 0
+	case dungeonFieldBossKey:
+		return dungeon.BossKey
+	case dungeonFieldMap, dungeonFieldCompass:
+		return dungeon.MapCompass
+	default:
+		return false
+	}
+}
+
+// flag returns a pointer to the boolean holding the field, or nil if it is
+// not a boolean.
+func (state *DungeonState) flag(field dungeonField) *bool {
+	switch field {
+	case dungeonFieldCompleted:
+		return &state.Completed
+	case dungeonFieldMQ:
+		return &state.MQ
+	case dungeonFieldBossKey:
+		return &state.BossKey
+	case dungeonFieldMap:
+		return &state.Map
+	case dungeonFieldCompass:
+		return &state.Compass
+	default:
+		return nil
+	}
+}
+
+// resetDungeonStates clears the state of all panel dungeons.
+func (tracker *Tracker) resetDungeonStates() {
+	tracker.dungeonStates = make([]DungeonState, len(tracker.panelDungeons))
+	for k, v := range tracker.panelDungeons {
+		tracker.dungeonStates[k].Name = v.Name
+	}
+}
+
+func (tracker *Tracker) getPanelDungeonIndexByName(name string) int {
+	for k := range tracker.panelDungeons {
+		if tracker.panelDungeons[k].Name == name {
+			return k
+		}
+	}
+
+	return -1
+}
+
+// setDungeonState restores the state of a dungeon, small keys are clamped to
+// the variant.
+func (tracker *Tracker) setDungeonState(state DungeonState) {
+	index := tracker.getPanelDungeonIndexByName(state.Name)
+	if index < 0 {
+		log.Printf("warning: unknown dungeon in state: %s", state.Name)
+		return
+	}
+
+	state.Keys = clamp(state.Keys, 0, tracker.panelDungeons[index].maxKeys(state.MQ))
+	tracker.dungeonStates[index] = state
+}
+
+// changeDungeon sets a field of a dungeon: flags are set if up is true and
+// cleared otherwise, small keys are added or removed one at a time.
+func (tracker *Tracker) changeDungeon(index int, field dungeonField, up bool) {
+	dungeon := tracker.panelDungeons[index]
+	if !dungeon.has(field) {
+		return
+	}
+
+	tracker.updateDungeon(index, func(state *DungeonState) bool {
+		if field == dungeonFieldKeys {
+			keys := state.Keys - 1
+			if up {
+				keys = state.Keys + 1
+			}
+			keys = clamp(keys, 0, dungeon.maxKeys(state.MQ))
+			if keys == state.Keys {
+				return false
+			}
+
+			state.Keys = keys
+			return true
+		}
+
+		flag := state.flag(field)
+		if *flag == up {
+			return false
+		}
+
+		*flag = up
+		state.Keys = clamp(state.Keys, 0, dungeon.maxKeys(state.MQ))
+		return true
+	})
+}
+
+// toggleDungeon flips a flag of a dungeon.
+func (tracker *Tracker) toggleDungeon(index int, field dungeonField) {
+	state := tracker.dungeonStates[index]
+	if flag := state.flag(field); flag != nil {
+		tracker.changeDungeon(index, field, !*flag)
+	}
+}
+
+// dungeonColumnX returns the X offset of a field in a panel line, relative to
+// the panel origin.
+func (tracker *Tracker) dungeonColumnX(field dungeonField) int {
+	var total int
+	for _, v := range dungeonColumns {
+		total += v.weight
+	}
+
+	var x int
+	for _, v := range dungeonColumns {
+		if v.field == field {
+			break
+		}
+		x += v.weight
+	}
+
+	return x * tracker.dungeonSize.X / total
+}
+
+// dungeonLineRect returns the rectangle of a panel line, relative to the
+// panel origin, and false if it does not fit in the panel.
+func (tracker *Tracker) dungeonLineRect(index int) (image.Rectangle, bool) {
+	rect := image.Rect(0, index*DungeonLineHeight, tracker.dungeonSize.X, (index+1)*DungeonLineHeight)
+	return rect, rect.Max.Y <= tracker.dungeonSize.Y
+}
+
+// dungeonFieldAt returns the dungeon and field under the given point.
+func (tracker *Tracker) dungeonFieldAt(x, y int) (int, dungeonField, bool) {
+	pos := image.Point{x, y}.Sub(tracker.dungeonPos)
+	if !pos.In(image.Rectangle{Max: tracker.dungeonSize}) {
+		return -1, dungeonFieldNone, false
+	}
+
+	index := pos.Y / DungeonLineHeight
+	if _, ok := tracker.dungeonLineRect(index); !ok || index >= len(tracker.panelDungeons) {
+		return -1, dungeonFieldNone, false
+	}
+
+	field := dungeonFieldNone
+	for _, v := range dungeonColumns {
+		if pos.X >= tracker.dungeonColumnX(v.field) {
+			field = v.field
+		}
+	}
+
+	return index, field, true
+}
+
+// dungeonZoneLines returns the first and last (excluded) panel lines of a
+// keypad zone, the panel being split in three zones from top to bottom.
+func (tracker *Tracker) dungeonZoneLines(zone int) (int, int) {
+	visible := 0
+	for visible < len(tracker.panelDungeons) {
+		if _, ok := tracker.dungeonLineRect(visible); !ok {
+			break
+		}
+		visible++
+	}
+
+	perZone := (visible + 2) / 3
+	start := clamp(zone*perZone, 0, visible)
+	end := clamp(start+perZone, 0, visible)
+
+	return start, end
+}
+
+// kpToDungeonZone returns the zone of the panel on the same row as the
+// keypad number, from 0 at the top to 2 at the bottom, or -1.
+func kpToDungeonZone(kp int) int {
+	if kp < 1 || kp > 9 {
+		return -1
+	}
+
+	return 2 - (kp-1)/3
+}
+
+// dungeonLineKP returns the keypad number selecting a panel line in the
+// active zone, or -1 if the line is not in it.
+func (tracker *Tracker) dungeonLineKP(index int) int {
+	if tracker.input.dungeonZone < 0 {
+		return -1
+	}
+
+	start, end := tracker.dungeonZoneLines(tracker.input.dungeonZone)
+	if index < start || index >= end || index-start >= len(dungeonKPOrder) {
+		return -1
+	}
+
+	return dungeonKPOrder[index-start]
+}
+
+// kpDungeonField returns the field of a dungeon bound to a keypad number, and
+// for small keys whether one is added or removed.
+func kpDungeonField(kp int) (dungeonField, bool) {
+	switch kp {
+	case 7:
+		return dungeonFieldMQ, true
+	case 8:
+		return dungeonFieldKeys, true
+	case 2:
+		return dungeonFieldKeys, false
+	case 9:
+		return dungeonFieldBossKey, true
+	case 4:
+		return dungeonFieldMap, true
+	case 6:
+		return dungeonFieldCompass, true
+	case 5:
+		return dungeonFieldCompleted, true
+	default:
+		return dungeonFieldNone, false
+	}
+}
+
+// dungeonHandleAction selects a dungeon with the keypad: first the zone of
+// the panel on the same row as the key, then the line of the zone, pressing
+// the action starting the selection again goes back to the zone.
+func (tracker *Tracker) dungeonHandleAction(a action) {
+	if a == actionStartDungeonInput {
+		tracker.input.dungeonZone = -1
+		return
+	}
+
+	kp := actionToKPZone(a)
+	if tracker.input.dungeonZone < 0 {
+		zone := kpToDungeonZone(kp)
+		if start, end := tracker.dungeonZoneLines(zone); zone < 0 || start == end {
+			// Reset on wrong input so we can start typing the correct "code" right away.
+			tracker.input.reset()
+			return
+		}
+
+		tracker.input.dungeonZone = zone
+		return
+	}
+
+	start, end := tracker.dungeonZoneLines(tracker.input.dungeonZone)
+	for k := start; k < end; k++ {
+		if kp > 0 && tracker.dungeonLineKP(k) == kp {
+			tracker.input.state = inputStateDungeonFieldInput
+			tracker.input.dungeonIndex = k
+			return
+		}
+	}
+
+	tracker.input.reset()
+}
+
+// dungeonFieldHandleAction changes the field bound to the keypad number of
+// the selected dungeon.
+func (tracker *Tracker) dungeonFieldHandleAction(a action) {
+	defer tracker.input.reset()
+
+	field, up := kpDungeonField(actionToKPZone(a))
+	switch field {
+	case dungeonFieldNone:
+		return
+	case dungeonFieldKeys:
+		tracker.changeDungeon(tracker.input.dungeonIndex, field, up)
+	default:
+		tracker.toggleDungeon(tracker.input.dungeonIndex, field)
+	}
+}
+
+// dungeonInputText returns the text to display on the input line while
+// selecting a dungeon.
+func (tracker *Tracker) dungeonInputText() string {
+	if tracker.kbInputStateIs(inputStateDungeonFieldInput) {
+		name := tracker.panelDungeons[tracker.input.dungeonIndex].Name
+		return name + ": 7 MQ, 8/2 key, 9 BK, 4 map, 6 compass, 5 done"
+	}
+
+	if tracker.input.dungeonZone < 0 {
+		return "dungeon: 7/8/9 top, 4/5/6 middle, 1/2/3 bottom"
+	}
+
+	return "dungeon"
+}
+
+func (tracker *Tracker) drawDungeons(screen *ebiten.Image) {
+	selecting := tracker.kbInputStateIs(inputStateDungeonInput)
+	if selecting {
+		tracker.drawDungeonZones(screen)
+	}
+
+	for k, dungeon := range tracker.panelDungeons {
+		rect, ok := tracker.dungeonLineRect(k)
+		if !ok {
+			break
+		}
+		rect = rect.Add(tracker.dungeonPos)
+		state := tracker.dungeonStates[k]
+
+		if tracker.kbInputStateIs(inputStateDungeonFieldInput) && tracker.input.dungeonIndex == k {
+			ebitenutil.DrawRect(
+				screen,
+				float64(rect.Min.X), float64(rect.Min.Y),
+				float64(rect.Dx()), float64(rect.Dy()),
+				color.RGBA{0xFF, 0xFF, 0xFF, 0x30},
+			)
+		}
+
+		y := rect.Min.Y + dungeonBaseline
+		draw := func(field dungeonField, str string, set bool) {
+			if !dungeon.has(field) {
+				return
+			}
+
+			c := color.RGBA{0x60, 0x60, 0x60, 0xFF}
+			if set {
+				c = color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+			}
+			if field == dungeonFieldCompleted && set {
+				c = color.RGBA{0x40, 0xD0, 0x40, 0xFF}
+			}
+
+			x := rect.Min.X + tracker.dungeonColumnX(field) + 4
+			text.Draw(screen, str, tracker.fontSmall, x, y, c)
+		}
+
+		label := dungeon.label()
+		if kp := tracker.dungeonLineKP(k); selecting && kp > 0 {
+			label = strconv.Itoa(kp) + " " + label
+		}
+
+		draw(dungeonFieldCompleted, label, state.Completed)
+		draw(dungeonFieldMQ, "MQ", state.MQ)
+		draw(dungeonFieldKeys, fmt.Sprintf("%d/%d", state.Keys, dungeon.maxKeys(state.MQ)), state.Keys > 0)
+		draw(dungeonFieldBossKey, "BK", state.BossKey)
+		draw(dungeonFieldMap, "M", state.Map)
+		draw(dungeonFieldCompass, "C", state.Compass)
+	}
+}
+
+// drawDungeonZones highlights the middle zone of the panel while choosing one,
+// or the chosen zone while choosing one of its dungeons.
+func (tracker *Tracker) drawDungeonZones(screen *ebiten.Image) {
+	zone, alpha := tracker.input.dungeonZone, uint8(0x50)
+	if zone < 0 {
+		zone, alpha = 1, 0x20
+	}
+
+	start, end := tracker.dungeonZoneLines(zone)
+	if start == end {
+		return
+	}
+
+	rect := image.Rect(0, start*DungeonLineHeight, tracker.dungeonSize.X, end*DungeonLineHeight).Add(tracker.dungeonPos)
+	ebitenutil.DrawRect(
+		screen,
+		float64(rect.Min.X), float64(rect.Min.Y),
+		float64(rect.Dx()), float64(rect.Dy()),
+		color.RGBA{0xFF, 0xFF, 0xFF, alpha},
+	)
+}
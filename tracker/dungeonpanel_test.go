@@ -0,0 +1,80 @@
+package tracker
+
+import (
+	"image"
+	"testing"
+)
+
+func TestDungeonColumns(t *testing.T) {
+	tracker := newTestTracker("")
+	for _, width := range []int{190, 294, 400} {
+		tracker.dungeonSize = image.Point{width, tracker.dungeonSize.Y}
+
+		prev := -1
+		for _, v := range dungeonColumns {
+			x := tracker.dungeonColumnX(v.field)
+			if x <= prev || x >= width {
+				t.Errorf("%dpx: field %d at %d, previous at %d", width, v.field, x, prev)
+			}
+			prev = x
+
+			if index, field, ok := tracker.dungeonFieldAt(x+1, 1); !ok || index != 0 || field != v.field {
+				t.Errorf("%dpx: clicking at %d gives %d %d %t, want field %d", width, x+1, index, field, ok, v.field)
+			}
+		}
+	}
+}
+
+func TestDungeonZoneLines(t *testing.T) {
+	for _, c := range []struct {
+		dungeons, visible int
+		want              [3][2]int
+	}{
+		{12, 12, [3][2]int{{0, 4}, {4, 8}, {8, 12}}},
+		{7, 7, [3][2]int{{0, 3}, {3, 6}, {6, 7}}},
+		{2, 2, [3][2]int{{0, 1}, {1, 2}, {2, 2}}},
+		{12, 6, [3][2]int{{0, 2}, {2, 4}, {4, 6}}},
+	} {
+		tracker := newTestTracker("")
+		tracker.panelDungeons = make([]PanelDungeon, c.dungeons)
+		tracker.dungeonSize.Y = c.visible * DungeonLineHeight
+
+		for zone, want := range c.want {
+			if start, end := tracker.dungeonZoneLines(zone); start != want[0] || end != want[1] {
+				t.Errorf("%d/%d dungeons, zone %d: got [%d, %d), want %v", c.visible, c.dungeons, zone, start, end, want)
+			}
+		}
+	}
+}
+
+func TestDungeonKeypadSelection(t *testing.T) {
+	tracker := newTestTracker("")
+	tracker.panelDungeons = make([]PanelDungeon, 9)
+	tracker.resetDungeonStates()
+	tracker.dungeonSize.Y = 9 * DungeonLineHeight
+
+	for _, c := range []struct {
+		name    string
+		actions []action
+		want    int // selected dungeon, -1 if the selection was dropped
+	}{
+		{"top zone, first line", []action{actionStartDungeonInput, actionTop, actionTopLeft}, 0},
+		{"middle zone, last line", []action{actionStartDungeonInput, actionLeft, actionTopRight}, 5},
+		{"bottom zone, second line", []action{actionStartDungeonInput, actionBottomRight, actionTop}, 7},
+		{"back to the zones", []action{actionStartDungeonInput, actionTop, actionStartDungeonInput, actionBottom, actionTopLeft}, 6},
+		{"no such line", []action{actionStartDungeonInput, actionTop, actionMiddle}, -1},
+	} {
+		tracker.input.reset()
+		for _, a := range c.actions {
+			tracker.inputAction(a)
+		}
+
+		got := -1
+		if tracker.kbInputStateIs(inputStateDungeonFieldInput) {
+			got = tracker.input.dungeonIndex
+		}
+		if got != c.want {
+			t.Errorf("%s: got %d, want %d", c.name, got, c.want)
+		}
+	}
+}
@@ -1,171 +1,645 @@
 package tracker
 
 import (
+	"encoding/json"
 	"image"
 	"image/color"
 	"log"
-	"sort"
 	"strings"
 
 	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
 	"github.com/hajimehoshi/ebiten/text"
-	"github.com/lithammer/fuzzysearch/fuzzy"
 )
 
-func (tracker *Tracker) AddWOTH(str string) bool {
-	if len(tracker.woths) >= 5 {
-		log.Printf("warning: WotHs maxed out at 5")
-		return false
+const (
+	hintLineHeight = 21
+	hintMarginLeft = 3
+	hintBaseline   = 15 // text baseline from the top of a line
+	hintSpriteSize = 17 // size of the item sprites displayed next to hints
+)
+
+// HintMatch defines what the text of a hint is fuzzy-matched against.
+type HintMatch string
+
+const (
+	HintMatchFreeform HintMatch = "freeform"
+	HintMatchLocation HintMatch = "location"
+	HintMatchItem     HintMatch = "item"
+
+	// The hint is a check followed by the item it holds, eg. "skull mask
+	// hookshot". For slotted categories the slot is the check.
+	HintMatchLocationItem HintMatch = "locationItem"
+)
+
+// HintCategory declares a kind of hint (WotH, Barren, etc.), how it is input
+// and where it is displayed.
+type HintCategory struct {
+	Name   string
+	Hotkey string    // single character that starts the text input
+	Match  HintMatch `json:",omitempty"` // defaults to freeform
+
+	// Warn when a location hint does not match this kind of location.
+	LocationKind LocationKind `json:",omitempty"`
+
+	// Maximum number of hints. If Slots is set, the category instead has one
+	// fixed slot per hint selected by the first word of the input.
+	// Both can be overridden by the HintProfile.
+	Capacity int        `json:",omitempty"`
+	Slots    []HintSlot `json:",omitempty"`
+
+	// Hints are displayed one per line flowing through Regions in order,
+	// regions are relative to the hint tracker origin.
+	Regions []image.Rectangle
+	Color   color.RGBA // defaults to black
+}
+
+// HintSlot is a fixed hint location (eg. an Always hint), it can be selected
+// by any of its aliases or by fuzzy matching its name.
+type HintSlot struct {
+	Name    string
+	Aliases []string `json:",omitempty"`
+}
+
+// HintProfile is a hint distribution, it overrides the capacity and slots of
+// hint categories by name.
+type HintProfile map[string]HintDistribution
+
+// HintDistribution overrides the fields it sets, the others are kept from the
+// hint category.
+type HintDistribution struct {
+	Capacity int        `json:",omitempty"`
+	Slots    []HintSlot `json:",omitempty"`
+}
+
+// hintStatus is the progress of the player on a hint.
+type hintStatus int
+
+const (
+	hintStatusNone       hintStatus = iota
+	hintStatusInProgress            // eg. region being checked
+	hintStatusCleared               // eg. region fully checked or item collected
+	hintStatusDead                  // eg. barren region confirmed skipped
+	hintStatusCount
+)
+
+// hint is a single hint, an empty Text is an unset slot.
+type hint struct {
+	Text   string
+	Item   string     `json:",omitempty"` // name of the referenced tracker item
+	Status hintStatus `json:",omitempty"`
+}
+
+// String returns the hint as displayed on the input line.
+func (h hint) String() string {
+	if h.Item == "" || h.Item == h.Text {
+		return h.Text
 	}
 
-	tracker.woths = append(tracker.woths, str)
-	return true
+	return h.Text + " - " + h.Item
 }
 
-func (tracker *Tracker) AddBarren(str string) bool {
-	if len(tracker.barrens) >= 3 {
-		log.Printf("warning: barrens maxed out at 3")
-		return false
+// input returns the text input that would parse back to this hint.
+func (h hint) input() string {
+	if h.Item == "" || h.Item == h.Text {
+		return h.Text
 	}
 
-	tracker.barrens = append(tracker.barrens, str)
-	return true
+	return h.Text + " " + h.Item
 }
 
-func (tracker *Tracker) AddSometimes(str string) bool {
-	if len(tracker.sometimes) >= 5 {
-		log.Printf("warning: sometimes maxed out at 5")
-		return false
+// UnmarshalJSON also accepts a plain string as saved before hints had a status.
+func (h *hint) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		*h = hint{Text: str}
+		return nil
 	}
 
-	tracker.sometimes = append(tracker.sometimes, str)
-	return true
+	type plain hint
+	return json.Unmarshal(data, (*plain)(h))
 }
 
-func (tracker *Tracker) AddAlways(str string) bool {
-	index, item := parseAlways(str)
-	if index > -1 {
-		tracker.setAlways(index, item)
-		return true
+// hintCategory is a configured category along with its current hints.
+type hintCategory struct {
+	HintCategory
+	hints []hint // one per slot for slotted categories
+}
+
+func newHintCategories(categories []HintCategory, profile HintProfile) []hintCategory {
+	ret := make([]hintCategory, len(categories))
+	for k, v := range categories {
+		if dist, ok := profile[v.Name]; ok {
+			if dist.Capacity > 0 {
+				v.Capacity = dist.Capacity
+			}
+			if dist.Slots != nil {
+				v.Slots = dist.Slots
+			}
+		}
+
+		ret[k] = hintCategory{HintCategory: v}
+		if ret[k].isSlotted() {
+			ret[k].Capacity = len(v.Slots)
+			ret[k].hints = make([]hint, len(v.Slots))
+		}
 	}
 
-	log.Printf("warning: could not parse %s", str)
-	return false
+	return ret
+}
+
+// HintProfile returns the name of the hint profile in use, it differs from the
+// configured one after restoring a state saved with another profile.
+func (tracker *Tracker) HintProfile() string {
+	return tracker.hintProfile
+}
+
+// setHintProfile switches to another configured hint profile, or to none if
+// name is empty, dropping all hints.
+func (tracker *Tracker) setHintProfile(name string) {
+	if name == tracker.hintProfile {
+		return
+	}
+
+	profile, ok := tracker.hintProfiles[name]
+	if !ok && name != "" {
+		log.Printf("warning: unknown hint profile: %s", name)
+		return
+	}
+
+	tracker.hintProfile = name
+	tracker.hintCategories = newHintCategories(tracker.hintConfig, profile)
+	tracker.input.reset()
+}
+
+func (cat *hintCategory) isSlotted() bool {
+	return len(cat.Slots) > 0
 }
 
-var alwaysLocations = []string{ // nolint:gochecknoglobals
-	"Skull Mask", "Biggoron Sword",
-	"30 Gold Skullutulas",
-	"40 Gold Skullutulas",
-	"50 Gold Skullutulas",
-	"Ocarina of Time", "Frog 2",
+func (cat *hintCategory) color() color.Color {
+	if cat.Color.A == 0 {
+		return color.Black
+	}
+
+	return cat.Color
+}
+
+// lineRect returns the rectangle of the nth line of the category relative to
+// the hint tracker origin, or false if it does not fit in its regions.
+func (cat *hintCategory) lineRect(line int) (image.Rectangle, bool) {
+	if line < 0 {
+		return image.Rectangle{}, false
+	}
+
+	for _, region := range cat.Regions {
+		lines := region.Dy() / hintLineHeight
+		if line < lines {
+			min := region.Min.Add(image.Point{0, line * hintLineHeight})
+			return image.Rect(min.X, min.Y, region.Max.X, min.Y+hintLineHeight), true
+		}
+		line -= lines
+	}
+
+	return image.Rectangle{}, false
 }
 
-func parseAlways(str string) (int, string) {
+// parseSlot returns the index of the slot named by the first word of str and
+// the rest of str.
+func (cat *hintCategory) parseSlot(str string) (int, string) {
 	parts := strings.SplitN(str, " ", 2)
 	if len(parts) < 2 {
 		parts = append(parts, "")
 	}
-	if parts[0] == "" {
+
+	slots := cat.slotCandidates(parts[0])
+	if len(slots) == 0 {
 		return -1, ""
 	}
 
-	matches := fuzzy.RankFindFold(parts[0], alwaysLocations)
-	if len(matches) == 0 {
-		return -1, ""
+	return slots[0], parts[1]
+}
+
+// slotCandidates returns the indices of the slots matching a word in order of
+// preference: aliases then fuzzy matches from the closest.
+func (cat *hintCategory) slotCandidates(word string) []int {
+	if word == "" {
+		return nil
+	}
+
+	var ret []int
+	for k := range cat.Slots {
+		for _, alias := range cat.Slots[k].Aliases {
+			if strings.EqualFold(alias, word) {
+				ret = append(ret, k)
+				break
+			}
+		}
 	}
 
-	sort.Sort(matches)
-	switch matches[0].Target {
-	case "Skull Mask":
-		return 0, parts[1]
-	case "Biggoron Sword":
-		return 1, parts[1]
-	case "30 Gold Skullutulas":
-		return 2, parts[1]
-	case "40 Gold Skullutulas":
-		return 3, parts[1]
-	case "50 Gold Skullutulas":
-		return 4, parts[1]
-	case "Ocarina of Time":
-		return 5, parts[1]
-	case "Frog 2":
-		return 6, parts[1]
+	names := make([]string, len(cat.Slots))
+	for k := range cat.Slots {
+		names[k] = cat.Slots[k].Name
 	}
 
-	return -1, ""
+	for _, v := range rankCandidates(word, names) {
+		if !containsInt(ret, v.OriginalIndex) {
+			ret = append(ret, v.OriginalIndex)
+		}
+	}
+
+	return ret
 }
 
-func (tracker *Tracker) setAlways(index int, str string) {
-	if index < 0 || index >= len(tracker.always) {
-		log.Printf(`bad index in setAlways(%d, "%s")`, index, str)
-		return
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// add adds a hint to the category, slot is ignored for non-slotted
+// categories. It returns false if the hint could not be added.
+func (cat *hintCategory) add(slot int, h hint) bool {
+	if cat.isSlotted() {
+		if slot < 0 || slot >= len(cat.hints) {
+			log.Printf(`bad slot in %s hint (%d, "%s")`, cat.Name, slot, h.Text)
+			return false
+		}
+
+		cat.hints[slot] = h
+		return true
+	}
+
+	if len(cat.hints) >= cat.Capacity {
+		log.Printf("warning: %s hints maxed out at %d", cat.Name, cat.Capacity)
+		return false
+	}
+
+	cat.hints = append(cat.hints, h)
+	return true
+}
+
+// slotNames returns the names of the slots of the category, or nil if it is
+// not slotted.
+func (cat *hintCategory) slotNames() []string {
+	if !cat.isSlotted() {
+		return nil
+	}
+
+	ret := make([]string, len(cat.Slots))
+	for k, v := range cat.Slots {
+		ret[k] = v.Name
+	}
+
+	return ret
+}
+
+// remapSlots returns the hints of the from slots moved to the slots of the
+// same name in to, hints of slots missing from to are dropped.
+func remapSlots(from, to []string, hints []hint) []hint {
+	byName := make(map[string]hint, len(from))
+	for k := range from {
+		if k < len(hints) {
+			byName[from[k]] = hints[k]
+		}
+	}
+
+	ret := make([]hint, len(to))
+	for k := range to {
+		ret[k] = byName[to[k]]
 	}
 
-	tracker.always[index] = str
+	return ret
+}
+
+func (tracker *Tracker) getHintCategoryIndex(name string) int {
+	for k := range tracker.hintCategories {
+		if tracker.hintCategories[k].Name == name {
+			return k
+		}
+	}
+
+	return -1
+}
+
+func (tracker *Tracker) getHintCategoryIndexByHotkey(r rune) int {
+	for k := range tracker.hintCategories {
+		if tracker.hintCategories[k].Hotkey == string(r) {
+			return k
+		}
+	}
+
+	return -1
 }
 
 func (tracker *Tracker) drawHints(screen *ebiten.Image) {
-	lineHeight := tracker.hintSize.Y / 10
-	margins := image.Point{3, 15}
+	for k := range tracker.hintCategories {
+		cat := &tracker.hintCategories[k]
+		for line, v := range cat.hints {
+			rect, ok := cat.lineRect(line)
+			if !ok {
+				break
+			}
+
+			tracker.drawHint(screen, cat, v, rect.Add(tracker.hintPos))
+		}
+	}
+}
+
+// drawHint draws a single hint in the given line according to its status.
+// Slotted hints referencing an item only display its sprite.
+func (tracker *Tracker) drawHint(screen *ebiten.Image, cat *hintCategory, h hint, rect image.Rectangle) {
+	var c color.Color = cat.color()
+	switch h.Status {
+	case hintStatusInProgress:
+		c = color.RGBA{0x00, 0x40, 0xC0, 0xFF}
+	case hintStatusDead:
+		c = color.RGBA{0x00, 0x00, 0x00, 0x60}
+	}
+
+	pos := rect.Min.Add(image.Point{hintMarginLeft, hintBaseline})
+	spriteY := rect.Min.Y + (hintLineHeight-hintSpriteSize)/2
+	var width int
+
+	dim := h.Status == hintStatusDead
+	if cat.isSlotted() && tracker.drawHintSprite(screen, h.Item, image.Point{pos.X, spriteY}, dim) {
+		width = hintSpriteSize
+	} else {
+		text.Draw(screen, h.Text, tracker.fontSmall, pos.X, pos.Y, c)
+		width = text.MeasureString(h.Text, tracker.fontSmall).X
+
+		if h.Item != "" && h.Item != h.Text &&
+			tracker.drawHintSprite(screen, h.Item, image.Point{pos.X + width + hintMarginLeft, spriteY}, dim) {
+			width += hintMarginLeft + hintSpriteSize
+		}
+	}
+
+	if h.Status == hintStatusCleared {
+		y := float64(pos.Y - (templeFontSize / 3))
+		ebitenutil.DrawLine(screen, float64(pos.X), y, float64(pos.X+width), y, c)
+	}
+}
 
-	pos := tracker.hintPos.Add(margins)
-	for _, v := range tracker.woths {
-		text.Draw(screen, v, tracker.fontSmall, pos.X, pos.Y, color.Black)
-		pos.Y += lineHeight
+// drawHintSprite draws the sprite of the named item scaled down to fit a hint
+// line, it returns false if there is no such item.
+func (tracker *Tracker) drawHintSprite(screen *ebiten.Image, name string, pos image.Point, dim bool) bool {
+	if name == "" {
+		return false
 	}
 
-	pos = tracker.hintPos.Add(margins).Add(image.Point{tracker.hintSize.X / 2, 0})
-	for _, v := range tracker.barrens {
-		text.Draw(screen, v, tracker.fontSmall, pos.X, pos.Y, color.Black)
-		pos.Y += lineHeight
+	index, progression := tracker.getItemIndexByAnyName(name)
+	if index < 0 {
+		return false
 	}
 
-	pos = tracker.hintPos.Add(margins).Add(image.Point{tracker.hintSize.X / 2, 3 * lineHeight})
-	for _, v := range tracker.sometimes {
-		text.Draw(screen, v, tracker.fontSmall, pos.X, pos.Y, color.Black)
-		pos.Y += lineHeight
+	var op ebiten.DrawImageOptions
+	scale := float64(hintSpriteSize) / itemSpriteWidth
+	op.GeoM.Scale(scale, scale)
+	op.GeoM.Translate(float64(pos.X), float64(pos.Y))
+	if dim {
+		op.ColorM.Scale(1, 1, 1, 0.4)
 	}
+	_ = screen.DrawImage(
+		tracker.sheetEnabled.SubImage(tracker.items[index].hintSheetRect(progression)).(*ebiten.Image),
+		&op,
+	)
+
+	return true
+}
 
-	pos = tracker.hintPos.Add(margins).Add(image.Point{22, 5 * lineHeight})
-	for k, v := range tracker.always {
-		if k == 5 {
-			pos = pos.Add(image.Point{tracker.hintSize.X / 2, -2 * lineHeight})
+// hintedItem returns the index of the item referenced by the hint being input
+// or selected, or found by the item search, or whose value is being typed, or
+// -1.
+func (tracker *Tracker) hintedItem() int {
+	var h hint
+	switch {
+	case tracker.kbInputStateIs(inputStateTextInput):
+		_, h = tracker.parseTextInput()
+	case tracker.kbInputStateIs(inputStateItemSearch):
+		h.Item = tracker.itemSearchMatch()
+	case tracker.kbInputStateIs(inputStateValueInput):
+		return tracker.input.valueItem
+	case tracker.kbInputStateIs(inputStateHintSelection):
+		if tracker.isHintSelected() {
+			h = tracker.selectedHintCategory().hints[tracker.input.selectedHint]
 		}
+	}
 
-		text.Draw(screen, v, tracker.fontSmall, pos.X, pos.Y, color.Black)
-		pos.Y += lineHeight
+	if h.Item == "" {
+		return -1
 	}
+
+	index, _ := tracker.getItemIndexByAnyName(h.Item)
+	return index
 }
 
-func (tracker *Tracker) submitTextInput() {
-	defer tracker.input.reset()
+// drawHintedItem highlights on the grid the item referenced by the hint being
+// input or selected, or found by the item search.
+func (tracker *Tracker) drawHintedItem(screen *ebiten.Image) {
+	index := tracker.hintedItem()
+	if index < 0 {
+		return
+	}
 
-	if len(tracker.input.buf) == 0 {
+	rect := tracker.items[index].Rect().Add(tracker.pos)
+	ebitenutil.DrawRect(
+		screen,
+		float64(rect.Min.X), float64(rect.Min.Y),
+		float64(rect.Dx()), float64(rect.Dy()),
+		color.RGBA{0xFF, 0xFF, 0x00, 0x50},
+	)
+}
+
+// hintAt returns the category and hint index under the given pixel, or false
+// if there is no hint there.
+func (tracker *Tracker) hintAt(x, y int) (int, int, bool) {
+	pos := image.Point{x, y}.Sub(tracker.hintPos)
+	for k := range tracker.hintCategories {
+		cat := &tracker.hintCategories[k]
+		for line := range cat.hints {
+			rect, ok := cat.lineRect(line)
+			if !ok {
+				break
+			}
+
+			if pos.In(rect) {
+				return k, line, true
+			}
+		}
+	}
+
+	return -1, -1, false
+}
+
+// cycleHintStatus sets the next (or previous) status of a hint and records it
+// in the history.
+func (tracker *Tracker) cycleHintStatus(catIndex, index int, forward bool) {
+	cat := &tracker.hintCategories[catIndex]
+	if index < 0 || index >= len(cat.hints) || cat.hints[index].Text == "" {
 		return
 	}
 
+	offset := hintStatusCount - 1
+	if forward {
+		offset = 1
+	}
+
+	tracker.updateHints(cat.Name, func() bool {
+		cat.hints[index].Status = (cat.hints[index].Status + offset) % hintStatusCount
+		return true
+	})
+}
+
+// matchHintText returns str matched according to the category, the hint text
+// is str itself if there is no match. Slotted hints only have their item
+// matched as the slot already is the location.
+func (tracker *Tracker) matchHintText(cat *hintCategory, str string) hint {
+	var ret hint
+	switch cat.Match {
+	case HintMatchLocation:
+		ret.Text = tracker.matchLocation(str)
+	case HintMatchItem:
+		ret.Item = tracker.matchItem(str)
+		ret.Text = ret.Item
+	case HintMatchLocationItem:
+		if cat.isSlotted() {
+			ret.Item = tracker.matchItem(str)
+			ret.Text = ret.Item
+		} else {
+			ret.Text, ret.Item = tracker.matchLocationItem(str)
+		}
+	}
+
+	if ret.Text == "" {
+		ret.Text = str
+	}
+
+	return ret
+}
+
+// parseTextInput returns the slot (or -1) and matched hint for the current text
+// input buffer.
+func (tracker *Tracker) parseTextInput() (int, hint) {
+	slot, str := tracker.splitTextInput()
+	if slot < 0 && tracker.hintCategories[tracker.input.textInputFor].isSlotted() {
+		return -1, hint{}
+	}
+
+	cat := &tracker.hintCategories[tracker.input.textInputFor]
+	return slot, tracker.applyPick(tracker.matchHintText(cat, str))
+}
+
+// splitTextInput returns the slot (or -1) and the unmatched hint text of the
+// current text input buffer. When editing a hint, its slot is already known.
+func (tracker *Tracker) splitTextInput() (int, string) {
+	cat := &tracker.hintCategories[tracker.input.textInputFor]
 	str := string(tracker.input.buf)
-	if tracker.input.textInputFor == hintTypeWOTH ||
-		tracker.input.textInputFor == hintTypeBarren {
-		if match := tracker.matchLocation(str); match != "" {
-			str = match
+	if tracker.input.editHint {
+		return tracker.input.selectedHint, str
+	}
+
+	if cat.isSlotted() {
+		slot, str := cat.parseSlot(str)
+		if field, picked, ok := tracker.pickedCandidate(); ok && field == pickerFieldSlot {
+			for k := range cat.Slots {
+				if cat.Slots[k].Name == picked {
+					slot = k
+				}
+			}
 		}
+		return slot, str
+	}
+
+	return -1, str
+}
+
+// submitTextInput adds the hint being input, if it conflicts with existing
+// hints the input is kept until submitted a second time.
+func (tracker *Tracker) submitTextInput() {
+	if tracker.input.editHint {
+		tracker.submitHintEdit()
+		return
+	}
+
+	if len(tracker.input.buf) == 0 {
+		tracker.input.reset()
+		return
+	}
+
+	slot, h := tracker.parseTextInput()
+	if tracker.hintCategories[tracker.input.textInputFor].isSlotted() && slot < 0 {
+		log.Printf("warning: could not parse %s", string(tracker.input.buf))
+		tracker.input.reset()
+		return
+	}
+
+	if !tracker.confirmHint(tracker.input.textInputFor, slot, h, -1) {
+		return
 	}
 
-	var ok bool
-	switch tracker.input.textInputFor {
-	case hintTypeWOTH:
-		ok = tracker.AddWOTH(str)
-	case hintTypeBarren:
-		ok = tracker.AddBarren(str)
-	case hintTypeSometimes:
-		ok = tracker.AddSometimes(str)
-	case hintTypeAlways:
-		ok = tracker.AddAlways(str)
+	if tracker.addHint(tracker.input.textInputFor, slot, h) {
+		tracker.learnTextInput(h)
+		tracker.addInputHistory(string(tracker.input.buf))
 	}
+	tracker.input.reset()
+}
 
-	if ok {
-		tracker.appendHintToUndoStack(tracker.input.textInputFor, str)
+// addHint adds an already matched hint and records it in the history.
+func (tracker *Tracker) addHint(catIndex, slot int, h hint) bool {
+	cat := &tracker.hintCategories[catIndex]
+	return tracker.updateHints(cat.Name, func() bool {
+		return cat.add(slot, h)
+	})
+}
+
+// hints returns a copy of the hints of the given category.
+func (tracker *Tracker) hints(name string) []hint {
+	index := tracker.getHintCategoryIndex(name)
+	if index < 0 {
+		return nil
+	}
+
+	return append([]hint(nil), tracker.hintCategories[index].hints...)
+}
+
+// hintSlots returns the slot names of the given category, or nil if it is not
+// slotted.
+func (tracker *Tracker) hintSlots(name string) []string {
+	index := tracker.getHintCategoryIndex(name)
+	if index < 0 {
+		return nil
+	}
+
+	return tracker.hintCategories[index].slotNames()
+}
+
+// setHints replaces the hints of the given category. slots are the names of
+// the slots holding the hints when they were saved, if set the hints are
+// moved to the slots of the same name as the hint profile may have changed
+// since.
+func (tracker *Tracker) setHints(name string, hints []hint, slots []string) {
+	index := tracker.getHintCategoryIndex(name)
+	if index < 0 {
+		log.Printf("warning: unknown hint category: %s", name)
+		return
+	}
+
+	cat := &tracker.hintCategories[index]
+	switch {
+	case cat.isSlotted() && slots != nil:
+		cat.hints = remapSlots(slots, cat.slotNames(), hints)
+	case cat.isSlotted():
+		cat.hints = make([]hint, len(cat.Slots))
+		copy(cat.hints, hints)
+	default:
+		cat.hints = nil
+		for _, v := range hints {
+			if v.Text != "" { // unset slot of a previously slotted category
+				cat.hints = append(cat.hints, v)
+			}
+		}
 	}
 }
@@ -0,0 +1,139 @@
+package tracker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewHintCategories(t *testing.T) {
+	for _, c := range []struct {
+		profile    string
+		capacities []int
+		slots      []string
+	}{
+		{"", []int{2, 2, 3}, []string{"Skull Mask", "Biggoron", "Frogs"}},
+		{"wide", []int{4, 2, 3}, []string{"Skull Mask", "Biggoron", "Frogs"}},
+		{"moved", []int{2, 2, 3}, []string{"Frogs", "Skull Mask", "Ocarina"}},
+	} {
+		cfg := testConfig(c.profile)
+		categories := newHintCategories(cfg.HintCategories, cfg.HintProfiles[c.profile])
+
+		capacities := make([]int, len(categories))
+		for k := range categories {
+			capacities[k] = categories[k].Capacity
+		}
+		if !reflect.DeepEqual(capacities, c.capacities) {
+			t.Errorf("%q: capacities %v, want %v", c.profile, capacities, c.capacities)
+		}
+
+		if slots := categories[2].slotNames(); !reflect.DeepEqual(slots, c.slots) {
+			t.Errorf("%q: slots %v, want %v", c.profile, slots, c.slots)
+		}
+	}
+}
+
+func TestRemapSlots(t *testing.T) {
+	a, b, c := hint{Text: "a"}, hint{Text: "b"}, hint{Text: "c"}
+	for _, v := range []struct {
+		name     string
+		from, to []string
+		hints    []hint
+		want     []hint
+	}{
+		{"same slots", []string{"x", "y"}, []string{"x", "y"}, []hint{a, b}, []hint{a, b}},
+		{"reordered", []string{"x", "y"}, []string{"y", "x"}, []hint{a, b}, []hint{b, a}},
+		{"slot removed", []string{"x", "y", "z"}, []string{"z", "x"}, []hint{a, b, c}, []hint{c, a}},
+		{"slot added", []string{"x"}, []string{"w", "x"}, []hint{a}, []hint{{}, a}},
+		{"missing hints", []string{"x", "y"}, []string{"y", "x"}, []hint{a}, []hint{{}, a}},
+		{"no slots", nil, []string{"x"}, []hint{a}, []hint{{}}},
+	} {
+		if got := remapSlots(v.from, v.to, v.hints); !reflect.DeepEqual(got, v.want) {
+			t.Errorf("%s: got %v, want %v", v.name, got, v.want)
+		}
+	}
+}
+
+func TestSetHints(t *testing.T) {
+	a, b := hint{Text: "a"}, hint{Text: "b"}
+	for _, c := range []struct {
+		name     string
+		category string
+		hints    []hint
+		slots    []string
+		want     []hint
+	}{
+		{"by slot name", "Always", []hint{a, {}, b}, []string{"Skull Mask", "Biggoron", "Frogs"}, []hint{b, a, {}}},
+		{"by position", "Always", []hint{a, b}, nil, []hint{a, b, {}}},
+		{"unset slots dropped", "WotH", []hint{{}, a, {}, b}, nil, []hint{a, b}},
+		{"from a slotted category", "WotH", []hint{a, b}, []string{"x", "y"}, []hint{a, b}},
+	} {
+		tracker := newTestTracker("moved")
+		tracker.setHints(c.category, c.hints, c.slots)
+		if got := tracker.hints(c.category); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestHintsAcrossProfiles(t *testing.T) {
+	skull := hint{Text: "Skull Mask", Item: "Bow"}
+	frogs := hint{Text: "Frogs", Item: "Hookshot"}
+	biggoron := hint{Text: "Biggoron", Item: "Longshot"}
+
+	newTracker := func() *Tracker {
+		tracker := newTestTracker("")
+		tracker.addHint(2, 0, skull)
+		tracker.addHint(2, 1, biggoron)
+		tracker.addHint(2, 2, frogs)
+		return tracker
+	}
+
+	t.Run("set state", func(t *testing.T) {
+		state := newTracker().State()
+		tracker := newTestTracker("moved")
+		state.HintProfile = "moved"
+		tracker.SetState(state)
+
+		if got, want := tracker.hints("Always"), []hint{frogs, skull, {}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("reconfigure then undo", func(t *testing.T) {
+		tracker := newTracker()
+		tracker.Reconfigure(testConfig("moved"))
+		if got, want := tracker.hints("Always"), []hint{frogs, skull, {}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("reconfigure: got %v, want %v", got, want)
+		}
+
+		// The undone command was recorded with the previous slots.
+		tracker.undo()
+		if got, want := tracker.hints("Always"), []hint{{}, skull, {}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("undo: got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("undo reset", func(t *testing.T) {
+		tracker := newTracker()
+		tracker.Reset(testConfig("moved"))
+		if profile := tracker.HintProfile(); profile != "moved" {
+			t.Errorf("reset: profile %q, want moved", profile)
+		}
+		if got, want := tracker.hints("Always"), []hint{{}, {}, {}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("reset: got %v, want %v", got, want)
+		}
+
+		tracker.undo()
+		if profile := tracker.HintProfile(); profile != "" {
+			t.Errorf("undo: profile %q, want none", profile)
+		}
+		if got, want := tracker.hints("Always"), []hint{skull, biggoron, frogs}; !reflect.DeepEqual(got, want) {
+			t.Errorf("undo: got %v, want %v", got, want)
+		}
+
+		tracker.redo()
+		if profile := tracker.HintProfile(); profile != "moved" {
+			t.Errorf("redo: profile %q, want moved", profile)
+		}
+	})
+}
@@ -6,9 +6,12 @@ import (
 	"image/color"
 	"log"
 	"sort"
+	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
 	"github.com/hajimehoshi/ebiten/text"
 	"github.com/lithammer/fuzzysearch/fuzzy"
 )
@@ -17,21 +20,32 @@ type kbInput struct {
 	state             inputState
 	activeKPZone      int // visually tied to a keypad number
 	downgradeNextItem bool
+	valueNextItem     bool // ask for a value instead of upgrading the item
+	valueItem         int  // index of the item in inputStateValueInput
+	templeNextItem    bool // ask for a dungeon instead of upgrading the item
+	templeItem        int  // index of the item in inputStateTempleInput
+
+	// Zone of the panel selected in inputStateDungeonInput (-1 until a
+	// zone is chosen), and dungeon selected for inputStateDungeonFieldInput.
+	dungeonZone, dungeonIndex int
 
 	buf          []rune // text input buffer
-	textInputFor hintType
+	cursor       int    // position in buf where runes are inserted
+	textInputFor int    // index of the hint category, reset with the config
+	warning      string // conflict to confirm by submitting again
+	pick         int    // offset from the best match chosen in the picker
+
+	// Number of entries back in the input history, 0 being the draft typed
+	// before recalling older entries.
+	historyIndex int
+	draft        []rune
+
+	// Hint selected in inputStateHintSelection, editHint is set when the
+	// text input replaces this hint instead of adding a new one.
+	selectedCategory, selectedHint int
+	editHint                       bool
 }
 
-type hintType int
-
-const (
-	_ hintType = iota
-	hintTypeWOTH
-	hintTypeBarren
-	hintTypeSometimes
-	hintTypeAlways
-)
-
 type inputState int
 
 const (
@@ -45,12 +59,35 @@ const (
 
 	// Writing raw text for a fuzzy search
 	inputStateTextInput
+
+	// Selecting a hint to edit, delete, or move
+	inputStateHintSelection
+
+	// Writing raw text to find an item to upgrade
+	inputStateItemSearch
+
+	// Typing the count or level of an item selected using the keypad
+	inputStateValueInput
+
+	// Writing raw text to find the dungeon of a stone or medallion
+	inputStateTempleInput
+
+	// Asking for a dungeon of the dungeon panel
+	inputStateDungeonInput
+
+	// Asking for a field of the selected dungeon
+	inputStateDungeonFieldInput
 )
 
 func (tracker *Tracker) kbInputStateIs(v inputState) bool {
 	return tracker.input.state == v
 }
 
+// isTyping returns true if runes are inserted in the text input buffer.
+func (tracker *Tracker) isTyping() bool {
+	return tracker.kbInputStateIsAny(inputStateTextInput, inputStateItemSearch, inputStateTempleInput)
+}
+
 func (tracker *Tracker) kbInputStateIsAny(states ...inputState) bool {
 	for _, v := range states {
 		if tracker.input.state == v {
@@ -65,18 +102,47 @@ func (input *kbInput) reset() {
 	*input = kbInput{}
 }
 
+// endHintEdit leaves the text input editing a hint and returns to the hint
+// selection.
+func (input *kbInput) endHintEdit() {
+	input.state = inputStateHintSelection
+	input.editHint = false
+	input.setBuf(nil)
+	input.historyIndex = 0
+	input.draft = nil
+}
+
+// bufChanged resets what depended on the previous text input.
+func (input *kbInput) bufChanged() {
+	input.warning = ""
+	input.pick = 0
+}
+
 func (tracker *Tracker) Input(input []rune) {
 	if len(input) == 0 {
 		return
 	}
 
-	if tracker.input.state == inputStateTextInput {
-		tracker.input.buf = append(tracker.input.buf, input...)
-		return
-	}
-
 	for _, r := range input {
-		tracker.inputAction(runeToAction(r))
+		if tracker.isTyping() {
+			tracker.input.insert(r)
+			continue
+		}
+
+		if tracker.kbInputStateIs(inputStateValueInput) && unicode.IsDigit(r) {
+			tracker.input.insert(r)
+			continue
+		}
+
+		if tracker.kbInputStateIs(inputStateIdle) {
+			if index := tracker.getHintCategoryIndexByHotkey(r); index > -1 {
+				tracker.input.state = inputStateTextInput
+				tracker.input.textInputFor = index
+				continue
+			}
+		}
+
+		tracker.inputAction(tracker.runes[r])
 	}
 }
 
@@ -99,18 +165,25 @@ func (tracker *Tracker) idleHandleAction(a action) {
 		tracker.input.activeKPZone = actionToKPZone(a)
 		tracker.input.state = inputStateItemInput
 
-	case actionStartWOTHInput:
-		tracker.input.state = inputStateTextInput
-		tracker.input.textInputFor = hintTypeWOTH
-	case actionStartBarrenInput:
-		tracker.input.state = inputStateTextInput
-		tracker.input.textInputFor = hintTypeBarren
-	case actionStartAlwaysHintInput:
-		tracker.input.state = inputStateTextInput
-		tracker.input.textInputFor = hintTypeAlways
-	case actionStartSometimesHintInput:
-		tracker.input.state = inputStateTextInput
-		tracker.input.textInputFor = hintTypeSometimes
+	case actionStartHintSelection:
+		tracker.startHintSelection()
+
+	case actionStartItemSearch:
+		tracker.input.state = inputStateItemSearch
+
+	case actionStartValueInput:
+		tracker.input.state = inputStateItemKPZoneInput
+		tracker.input.valueNextItem = true
+
+	case actionStartTempleInput:
+		tracker.input.state = inputStateItemKPZoneInput
+		tracker.input.templeNextItem = true
+
+	case actionStartDungeonInput:
+		if len(tracker.panelDungeons) > 0 {
+			tracker.input.state = inputStateDungeonInput
+			tracker.input.dungeonZone = -1
+		}
 
 	case actionRedo:
 		tracker.redo()
@@ -138,10 +211,45 @@ func (tracker *Tracker) inputAction(a action) {
 			tracker.cancelTextInput()
 		}
 
+	case inputStateHintSelection:
+		tracker.selectionHandleAction(a)
+
+	case inputStateItemSearch:
+		switch a {
+		case actionSubmit:
+			tracker.submitItemSearch()
+		case actionCancel:
+			tracker.input.reset()
+		}
+
+	case inputStateValueInput:
+		switch a {
+		case actionSubmit:
+			tracker.submitValueInput()
+		case actionCancel:
+			tracker.input.reset()
+		}
+
+	case inputStateDungeonInput:
+		tracker.dungeonHandleAction(a)
+
+	case inputStateDungeonFieldInput:
+		tracker.dungeonFieldHandleAction(a)
+
+	case inputStateTempleInput:
+		switch a {
+		case actionSubmit:
+			tracker.submitTempleInput()
+		case actionCancel:
+			tracker.input.reset()
+		}
+
 	case inputStateItemKPZoneInput:
 		switch a {
 		case actionDowngradeNext:
 			tracker.input.downgradeNextItem = !tracker.input.downgradeNextItem
+		case actionStartItemSearch:
+			tracker.input.state = inputStateItemSearch
 		case actionTopLeft, actionTop, actionTopRight,
 			actionLeft, actionMiddle, actionRight,
 			actionBottomLeft, actionBottom, actionBottomRight:
@@ -174,12 +282,47 @@ func (tracker *Tracker) inputKPZoneItem(zoneKP, itemKP int) error {
 		return err
 	}
 
+	if tracker.input.valueNextItem {
+		tracker.input.state = inputStateValueInput
+		tracker.input.valueItem = index
+		return nil
+	}
+
+	if tracker.input.templeNextItem {
+		if !tracker.isDungeonReward(index) {
+			return fmt.Errorf("%s is not a dungeon reward", tracker.items[index].Name)
+		}
+		tracker.input.state = inputStateTempleInput
+		tracker.input.templeItem = index
+		return nil
+	}
+
 	tracker.changeItem(index, !tracker.input.downgradeNextItem)
 	tracker.input.reset()
 
 	return nil
 }
 
+// submitValueInput sets the count or level of the selected item to the typed
+// value as a single action.
+func (tracker *Tracker) submitValueInput() {
+	defer tracker.input.reset()
+
+	if len(tracker.input.buf) == 0 {
+		return
+	}
+
+	v, err := strconv.Atoi(string(tracker.input.buf))
+	if err != nil {
+		log.Printf("warning: invalid value %s: %s", string(tracker.input.buf), err)
+		return
+	}
+
+	tracker.updateItem(tracker.input.valueItem, func(item *Item) bool {
+		return item.SetValue(v)
+	})
+}
+
 func actionToKPZone(a action) int {
 	switch a {
 	case actionTopLeft:
@@ -205,31 +348,67 @@ func actionToKPZone(a action) int {
 	}
 }
 
-// DEBUG
+// drawInputState draws the input line below the items: the pending item
+// operation, or the prompt of the current text or keypad input.
 func (tracker *Tracker) drawInputState(screen *ebiten.Image) {
 	pos := tracker.pos.Add(image.Point{10, 15 + 9*gridSize})
 	var str string
 
 	switch tracker.input.state {
 	case inputStateItemInput, inputStateItemKPZoneInput:
-		if tracker.input.downgradeNextItem {
+		switch {
+		case tracker.input.valueNextItem:
+			str = "="
+		case tracker.input.templeNextItem:
+			str = "@"
+		case tracker.input.downgradeNextItem:
 			str = "-"
-		} else {
+		default:
 			str = "+"
 		}
 
+	case inputStateValueInput:
+		str = tracker.items[tracker.input.valueItem].Name + " = " + string(tracker.input.buf)
+
+	case inputStateTempleInput:
+		prefix := tracker.items[tracker.input.templeItem].Name + " @ "
+		str = prefix + string(tracker.input.buf)
+		tracker.drawCursor(screen, pos, prefix)
+		if index := tracker.templeInputMatch(); index >= 0 && len(tracker.input.buf) > 0 {
+			str += " (" + tracker.dungeons[index].Name + ")"
+		}
+
+	case inputStateDungeonInput, inputStateDungeonFieldInput:
+		str = tracker.dungeonInputText()
+
+	case inputStateHintSelection:
+		str = "edit " + tracker.hintSelectionText()
+
+	case inputStateItemSearch:
+		str = "find " + string(tracker.input.buf)
+		tracker.drawCursor(screen, pos, "find ")
+		if match := tracker.itemSearchMatch(); match != "" {
+			if _, downgrade := tracker.itemSearchQuery(); downgrade {
+				str += " (-" + match + ")"
+			} else {
+				str += " (+" + match + ")"
+			}
+		}
+
 	case inputStateTextInput:
 		str = "> " + string(tracker.input.buf)
-		if tracker.input.textInputFor == hintTypeWOTH ||
-			tracker.input.textInputFor == hintTypeBarren {
-			if match := tracker.matchLocation(string(tracker.input.buf)); match != "" {
-				str += " (" + match + ")"
-			}
-		} else if tracker.input.textInputFor == hintTypeAlways {
-			index, _ := parseAlways(string(tracker.input.buf))
-			if index > -1 {
-				str += fmt.Sprintf(` (%s)`, alwaysLocations[index])
-			}
+		tracker.drawCursor(screen, pos, "> ")
+
+		if tracker.input.warning != "" {
+			text.Draw(screen, str, tracker.fontSmall, pos.X, pos.Y, color.White)
+			pos.X += text.MeasureString(str+" ", tracker.fontSmall).X
+			str = "! " + tracker.input.warning + ", Enter to confirm"
+			text.Draw(screen, str, tracker.fontSmall, pos.X, pos.Y, color.RGBA{0xFF, 0xA0, 0x00, 0xFF})
+			return
+		}
+
+		if preview := tracker.textInputPreview(); preview != "" {
+			str += " (" + preview + ")"
 		}
 	}
 
@@ -240,25 +419,132 @@ func (tracker *Tracker) drawInputState(screen *ebiten.Image) {
 	text.Draw(screen, str, tracker.fontSmall, pos.X, pos.Y, color.White)
 }
 
-func (tracker *Tracker) matchLocation(str string) string {
-	if str == "" { // this matches Market for some reason.
+// drawCursor draws the text input cursor on the input line drawn at pos and
+// starting with prefix.
+func (tracker *Tracker) drawCursor(screen *ebiten.Image, pos image.Point, prefix string) {
+	str := prefix + string(tracker.input.buf[:tracker.input.cursor])
+	x := float64(pos.X + text.MeasureString(str, tracker.fontSmall).X)
+	ebitenutil.DrawLine(screen, x, float64(pos.Y-templeFontSize), x, float64(pos.Y+2), color.White)
+}
+
+// textInputPreview returns what the current text input will be parsed as, or
+// an empty string if it is kept as is.
+func (tracker *Tracker) textInputPreview() string {
+	cat := &tracker.hintCategories[tracker.input.textInputFor]
+	slot, h := tracker.parseTextInput()
+	if !cat.isSlotted() {
+		if h.String() == string(tracker.input.buf) {
+			return ""
+		}
+		return h.String()
+	}
+
+	if slot < 0 {
 		return ""
 	}
 
-	// HACK: Force some established conventions.
-	switch strings.ToLower(strings.Trim(str, " ")) {
-	case "dc":
-		str = "Dodongo's Cavern"
-	case "ogc":
-		str = "Outside Ganon's Castle"
+	if cat.Match != "" && cat.Match != HintMatchFreeform {
+		return fmt.Sprintf("%s: %s", cat.Slots[slot].Name, h.String())
+	}
+
+	return cat.Slots[slot].Name
+}
+
+func (tracker *Tracker) matchLocation(str string) string {
+	match, _ := tracker.matchPlace(str, tracker.locations)
+	return match
+}
+
+func (tracker *Tracker) matchItem(str string) string {
+	match, _ := rankMatch(str, tracker.itemNames())
+	return match
+}
+
+// matchLocationItem returns the check and item matched from str.
+func (tracker *Tracker) matchLocationItem(str string) (string, string) {
+	split := tracker.splitLocationItem(str)
+	return split.location, split.item
+}
+
+// locationItemSplit is a text input split in a check and an item.
+type locationItemSplit struct {
+	locationQuery, itemQuery string // as typed
+	location, item           string // matched, item is empty if not found
+}
+
+// splitLocationItem splits str in a check and an item and fuzzy-matches both,
+// keeping the split with the closest matches. If no item can be found the
+// whole string is matched as a check.
+func (tracker *Tracker) splitLocationItem(str string) locationItemSplit {
+	words := strings.Fields(str)
+	names := tracker.itemNames()
+	ret := locationItemSplit{locationQuery: str}
+	best := -1
+
+	for k := 1; k < len(words); k++ {
+		lQuery, iQuery := strings.Join(words[:k], " "), strings.Join(words[k:], " ")
+		l, lDist := tracker.matchPlace(lQuery, tracker.checks)
+		i, iDist := rankMatch(iQuery, names)
+		if l == "" || i == "" {
+			continue
+		}
+
+		if best < 0 || lDist+iDist < best {
+			ret = locationItemSplit{lQuery, iQuery, l, i}
+			best = lDist + iDist
+		}
+	}
+
+	if best < 0 {
+		ret.location, _ = tracker.matchPlace(str, tracker.checks)
+	}
+
+	return ret
+}
+
+// itemNames returns the names of all items and of their progression, without
+// duplicates (eg. bottle contents).
+func (tracker *Tracker) itemNames() []string {
+	names := make([]string, 0, len(tracker.items))
+	seen := make(map[string]struct{}, len(tracker.items))
+	add := func(name string) {
+		if _, ok := seen[name]; ok {
+			return
+		}
+		seen[name] = struct{}{}
+		names = append(names, name)
+	}
+
+	for k := range tracker.items {
+		add(tracker.items[k].Name)
+		for _, v := range tracker.items[k].ItemProgression {
+			add(v.Name)
+		}
 	}
 
-	matches := fuzzy.RankFindFold(str, tracker.locations)
+	return names
+}
+
+// rankMatch returns the closest fuzzy match of str in targets along with its
+// distance, or an empty string if nothing matches.
+func rankMatch(str string, targets []string) (string, int) {
+	matches := rankCandidates(str, targets)
 	if len(matches) == 0 {
-		return ""
+		return "", -1
 	}
-	sort.Sort(matches)
-	return matches[0].Target
+
+	return matches[0].Target, matches[0].Distance
+}
+
+// rankCandidates returns the fuzzy matches of str in targets from the closest.
+func rankCandidates(str string, targets []string) fuzzy.Ranks {
+	if strings.TrimSpace(str) == "" { // an empty string matches everything
+		return nil
+	}
+
+	matches := fuzzy.RankFindFold(str, targets)
+	sort.Stable(matches)
+	return matches
 }
 
 type action int
@@ -268,16 +554,18 @@ const (
 	actionStartItemInput
 	actionDowngradeNext
 
-	actionStartWOTHInput
-	actionStartBarrenInput
-	actionStartAlwaysHintInput
-	actionStartSometimesHintInput
 	actionSubmit
 	actionCancel
 
 	actionUndo
 	actionRedo
 
+	actionStartHintSelection
+	actionStartItemSearch
+	actionStartValueInput
+	actionStartTempleInput
+	actionStartDungeonInput
+
 	actionTopLeft
 	actionTop
 	actionTopRight
@@ -289,55 +577,9 @@ const (
 	actionBottomRight
 )
 
-// runeToAction is the keyboard "binds" part, as we handle text input and not
-// keys we already are qwerty/azerty compatible but can't distinguish the main
-// keyboard from keypad.
-func runeToAction(r rune) action {
-	switch r {
-	case '0':
-		return actionStartItemInput
-	case '.':
-		return actionDowngradeNext
-	case '-':
-		return actionUndo
-	case '+':
-		return actionRedo
-
-	case 'w':
-		return actionStartWOTHInput
-	case 'b':
-		return actionStartBarrenInput
-	case 'a':
-		return actionStartAlwaysHintInput
-	case 's':
-		return actionStartSometimesHintInput
-
-	case '7':
-		return actionTopLeft
-	case '8':
-		return actionTop
-	case '9':
-		return actionTopRight
-	case '4':
-		return actionLeft
-	case '5':
-		return actionMiddle
-	case '6':
-		return actionRight
-	case '1':
-		return actionBottomLeft
-	case '2':
-		return actionBottom
-	case '3':
-		return actionBottomRight
-	default:
-		return actionIgnore
-	}
-}
-
 // Submit is called the user presses Enter.
 func (tracker *Tracker) Submit() {
-	if !tracker.kbInputStateIs(inputStateTextInput) {
+	if !tracker.EatInput() {
 		return
 	}
 
@@ -346,26 +588,37 @@ func (tracker *Tracker) Submit() {
 
 // Submit is called the user presses Escape.
 func (tracker *Tracker) Cancel() {
-	if !tracker.kbInputStateIs(inputStateTextInput) {
+	if !tracker.EatInput() {
 		return
 	}
 
 	tracker.inputAction(actionCancel)
 }
 
+// Backspace deletes the last character of the text input, or the selected
+// hint when selecting hints.
 func (tracker *Tracker) Backspace() {
-	if len(tracker.input.buf) == 0 {
+	if tracker.kbInputStateIs(inputStateHintSelection) {
+		tracker.deleteSelectedHint()
 		return
 	}
 
-	tracker.input.buf = tracker.input.buf[:len(tracker.input.buf)-1]
+	tracker.input.deleteBack(1)
 }
 
 func (tracker *Tracker) cancelTextInput() {
+	if tracker.input.editHint {
+		tracker.input.endHintEdit()
+		return
+	}
+
 	tracker.input.reset()
 }
 
 // EatInput returns true if the tracker should reserve all text inputs for itself.
 func (tracker *Tracker) EatInput() bool {
-	return tracker.kbInputStateIs(inputStateTextInput)
+	return tracker.kbInputStateIsAny(
+		inputStateTextInput, inputStateHintSelection, inputStateItemSearch, inputStateValueInput,
+		inputStateTempleInput,
+	)
 }
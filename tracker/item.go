@@ -2,6 +2,7 @@ package tracker
 
 import (
 	"image"
+	"log"
 )
 
 type Item struct {
@@ -13,13 +14,30 @@ type Item struct {
 	CapacityProgression []int  `json:",omitempty"`
 	ItemProgression     []Item `json:",omitempty"`
 
-	// Index of the current item/capacity and temple upgrade
-	upgradeIndex, templeIndex int
+	// Index of the current item/capacity upgrade
+	upgradeIndex int
+
+	// Name of the dungeon the reward was found in.
+	temple string
 
 	// For countable items.
 	CountMax, CountStep, count int
 
-	IsMedallion, IsSong, Enabled bool `json:",omitempty"`
+	IsSong, Enabled bool `json:",omitempty"`
+
+	// Only read from older configurations without DungeonRewards.
+	IsMedallion bool `json:",omitempty"`
+}
+
+// StartingItem is an item the player owns when starting a seed.
+type StartingItem struct {
+	Name string
+
+	// Index of the item/capacity upgrade, count for countable items, and
+	// dungeon name or label for dungeon rewards.
+	Upgrade int    `json:",omitempty"`
+	Count   int    `json:",omitempty"`
+	Temple  string `json:",omitempty"`
 }
 
 // Capacity returns the currently selected capacity of the item or -1 if it has
@@ -77,6 +95,22 @@ func (item Item) SheetRect() image.Rectangle {
 	)
 }
 
+// hintSheetRect returns the position on the spritesheet of the sprite used to
+// reference the item in hints. progression is the index of a named upgrade of
+// the item or -1 to use the first one, if any.
+func (item Item) hintSheetRect(progression int) image.Rectangle {
+	x, y := item.SheetX, item.SheetY
+	if len(item.ItemProgression) > 0 {
+		if progression < 0 || progression >= len(item.ItemProgression) {
+			progression = 0
+		}
+		x = item.ItemProgression[progression].SheetX
+		y = item.ItemProgression[progression].SheetY
+	}
+
+	return image.Rect(x, y, x+itemSpriteWidth, y+itemSpriteHeight)
+}
+
 // Upgrade upgrades the item to the next capacity or item upgrade (or both).
 // If the item was disabled it does not upgrade it but enables it.
 // It returns false if the item was not affected.
@@ -149,6 +183,46 @@ func (item *Item) countUp() {
 	}
 }
 
+// SetValue sets the count of a countable item, the capacity of an item having
+// a CapacityProgression, or the progression level of other items, 1 being the
+// first. 0 disables the item. It returns false if the item was not affected
+// or the value is invalid.
+func (item *Item) SetValue(v int) bool {
+	before := item.State()
+
+	switch {
+	case v < 0:
+		log.Printf("warning: negative value for %s", item.Name)
+		return false
+	case v == 0:
+		item.Enabled = false
+		item.count = 0
+	case item.IsCountable():
+		item.Enabled = true
+		item.count = clamp(v, 0, item.CountMax)
+	case item.HasCapacity():
+		index := -1
+		for k, capacity := range item.CapacityProgression {
+			if capacity == v {
+				index = k
+				break
+			}
+		}
+		if index < 0 {
+			log.Printf("warning: %s has no %d capacity, expected one of %v", item.Name, v, item.CapacityProgression)
+			return false
+		}
+
+		item.Enabled = true
+		item.upgradeIndex = index
+	default:
+		item.Enabled = true
+		item.upgradeIndex = clamp(v-1, 0, item.maxUpgradeIndex())
+	}
+
+	return item.State() != before
+}
+
 func (item *Item) Count() int {
 	return item.count
 }
@@ -161,31 +235,44 @@ func (item *Item) IsCountable() bool {
 	return item.CountMax != 0
 }
 
-// nolint:gochecknoglobals
-var temples = []string{
-	"", "Free",
-	"Deku", "DC", "Jabu",
-	"Forest", "Fire", "Water",
-	"Spirit", "Shdw",
+// State returns the mutable part of the item.
+func (item Item) State() ItemState {
+	return ItemState{
+		Name:         item.Name,
+		UpgradeIndex: item.upgradeIndex,
+		Temple:       item.temple,
+		Count:        item.count,
+		Enabled:      item.Enabled,
+	}
 }
 
-func (item *Item) CycleTempleUp() {
+// SetState restores the mutable part of the item, out of bounds values are
+// clamped to the item configuration.
+func (item *Item) SetState(state ItemState) {
+	item.Enabled = state.Enabled
+	item.upgradeIndex = clamp(state.UpgradeIndex, 0, item.maxUpgradeIndex())
+	item.temple = state.Temple
+	item.count = clamp(state.Count, 0, item.CountMax)
 }
 
-func (item *Item) CycleTemple(up bool) {
-	if up {
-		item.templeIndex = (item.templeIndex + 1) % len(temples)
-		return
+func (item *Item) maxUpgradeIndex() int {
+	switch {
+	case len(item.ItemProgression) > 0:
+		return len(item.ItemProgression) - 1
+	case len(item.CapacityProgression) > 0:
+		return len(item.CapacityProgression) - 1
+	default:
+		return 0
 	}
+}
 
-	if item.templeIndex-1 < 0 {
-		item.templeIndex = len(temples) - 1
-		return
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
 	}
 
-	item.templeIndex--
-}
-
-func (item *Item) TempleText() string {
-	return temples[item.templeIndex]
+	return v
 }
@@ -0,0 +1,47 @@
+package tracker
+
+import "testing"
+
+func TestSetValue(t *testing.T) {
+	bow := Item{Name: "Bow", CapacityProgression: []int{30, 40, 50}}
+	wallet := Item{
+		Name:                "Wallet",
+		CapacityProgression: []int{200, 500, 999},
+		ItemProgression:     []Item{{Name: "Adult"}, {Name: "Giant"}, {Name: "Tycoon"}},
+	}
+	hookshot := Item{Name: "Hookshot", ItemProgression: []Item{{Name: "Hookshot"}, {Name: "Longshot"}}}
+	skulls := Item{Name: "Gold Skulltula Token", CountMax: 100, CountStep: 1}
+
+	enabled := func(item Item, index, count int) Item {
+		item.Enabled, item.upgradeIndex, item.count = true, index, count
+		return item
+	}
+
+	for _, c := range []struct {
+		name    string
+		item    Item
+		value   int
+		changed bool
+		want    ItemState
+	}{
+		{"capacity", bow, 40, true, enabled(bow, 1, 0).State()},
+		{"capacity with items", wallet, 999, true, enabled(wallet, 2, 0).State()},
+		{"level of an item with capacities", bow, 2, false, bow.State()},
+		{"level", hookshot, 2, true, enabled(hookshot, 1, 0).State()},
+		{"level clamped", hookshot, 3, true, enabled(hookshot, 1, 0).State()},
+		{"count", skulls, 37, true, enabled(skulls, 0, 37).State()},
+		{"count clamped", skulls, 120, true, enabled(skulls, 0, 100).State()},
+		{"disable", enabled(bow, 2, 0), 0, true, ItemState{Name: "Bow", UpgradeIndex: 2}},
+		{"disable countable", enabled(skulls, 0, 12), 0, true, skulls.State()},
+		{"negative", enabled(hookshot, 1, 0), -1, false, enabled(hookshot, 1, 0).State()},
+		{"unchanged", enabled(hookshot, 1, 0), 2, false, enabled(hookshot, 1, 0).State()},
+	} {
+		item := c.item
+		if changed := item.SetValue(c.value); changed != c.changed {
+			t.Errorf("%s: changed %t, want %t", c.name, changed, c.changed)
+		}
+		if got := item.State(); got != c.want {
+			t.Errorf("%s: got %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package tracker
+
+import (
+	"log"
+	"strings"
+)
+
+// itemSearchQuery returns the item search text input and whether the found
+// item should be downgraded, either by pressing '.' before starting the search
+// or by starting the text with a '.'.
+func (tracker *Tracker) itemSearchQuery() (string, bool) {
+	str := string(tracker.input.buf)
+	downgrade := tracker.input.downgradeNextItem
+	if strings.HasPrefix(str, ".") {
+		str = str[1:]
+		downgrade = !downgrade
+	}
+
+	return str, downgrade
+}
+
+// itemSearchMatch returns the item or progression name the search matches,
+// taking the picker into account.
+func (tracker *Tracker) itemSearchMatch() string {
+	if _, picked, ok := tracker.pickedCandidate(); ok {
+		return picked
+	}
+
+	query, _ := tracker.itemSearchQuery()
+	return tracker.matchItem(query)
+}
+
+// submitItemSearch upgrades (or downgrades) the item found by the search, up
+// to the matched progression level if a progression name was matched.
+func (tracker *Tracker) submitItemSearch() {
+	defer tracker.input.reset()
+
+	_, downgrade := tracker.itemSearchQuery()
+	name := tracker.itemSearchMatch()
+	index, level := tracker.getItemIndexByAnyName(name)
+	if index < 0 {
+		log.Printf("warning: no item matching %s", string(tracker.input.buf))
+		return
+	}
+
+	tracker.addInputHistory(string(tracker.input.buf))
+	if level < 0 {
+		tracker.changeItem(index, !downgrade)
+		return
+	}
+
+	tracker.setItemLevel(index, level, downgrade)
+}
+
+// setItemLevel sets an item to the given progression level, or right under it
+// when downgrading.
+func (tracker *Tracker) setItemLevel(index, level int, downgrade bool) {
+	tracker.updateItem(index, func(item *Item) bool {
+		if downgrade {
+			if !item.Enabled || item.upgradeIndex < level {
+				return false
+			}
+
+			if level == 0 {
+				item.Enabled = false
+			} else {
+				item.upgradeIndex = level - 1
+			}
+			return true
+		}
+
+		if item.Enabled && item.upgradeIndex == level {
+			return false
+		}
+
+		item.Enabled = true
+		item.upgradeIndex = level
+		return true
+	})
+}
@@ -0,0 +1,108 @@
+package tracker
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Journal receives every action applied to the tracker, in order, so they can
+// be replayed later on using Tracker.Replay.
+type Journal interface {
+	Record(Event)
+}
+
+type EventKind string
+
+const (
+	EventDo   EventKind = "do" // Command was applied
+	EventUndo EventKind = "undo"
+	EventRedo EventKind = "redo"
+)
+
+// Event is a single action applied to the tracker.
+type Event struct {
+	Kind    EventKind
+	Command *command `json:",omitempty"`
+}
+
+// SetJournal sets the journal that will record all subsequent actions, nil
+// disables journaling.
+func (tracker *Tracker) SetJournal(journal Journal) {
+	tracker.journal = journal
+}
+
+func (tracker *Tracker) record(event Event) {
+	if tracker.journal == nil {
+		return
+	}
+
+	tracker.journal.Record(event)
+}
+
+// Replay applies a previously recorded event to the tracker. Commands are not
+// run again, the state they recorded after the change is set and they are
+// added to the history. Undo and redo go through the history as the original
+// action did. An error is returned if the state a command was recorded from
+// differs from the current one, the command is applied anyway.
+func (tracker *Tracker) Replay(event Event) error {
+	switch event.Kind {
+	case EventDo:
+		if event.Command == nil {
+			return errors.New("missing command")
+		}
+		err := tracker.checkBefore(*event.Command)
+		tracker.apply(*event.Command, true)
+		tracker.commit(*event.Command)
+		return err
+
+	case EventUndo:
+		tracker.undo()
+	case EventRedo:
+		tracker.redo()
+
+	default:
+		return fmt.Errorf("unknown event kind: %s", event.Kind)
+	}
+
+	return nil
+}
+
+// checkBefore returns an error if the current state differs from the state the
+// command was applied to when it was recorded. Resets are not checked as they
+// also change the configuration.
+func (tracker *Tracker) checkBefore(cmd command) error {
+	switch cmd.Kind {
+	case commandItem:
+		index := tracker.getItemIndexByName(cmd.ItemBefore.Name)
+		if index < 0 {
+			return fmt.Errorf("unknown item %s", cmd.ItemBefore.Name)
+		}
+		if tracker.items[index].State() != cmd.ItemBefore {
+			return fmt.Errorf("item %s diverged from the journal", cmd.ItemBefore.Name)
+		}
+
+	case commandHints:
+		current := tracker.hints(cmd.Hint)
+		diverged := len(current) != len(cmd.HintsBefore)
+		for k := 0; !diverged && k < len(current); k++ {
+			diverged = current[k] != cmd.HintsBefore[k]
+		}
+		if diverged {
+			return fmt.Errorf("hints %s diverged from the journal", cmd.Hint)
+		}
+
+	case commandDungeon:
+		if cmd.DungeonBefore == nil {
+			return errors.New("missing dungeon state")
+		}
+		index := tracker.getPanelDungeonIndexByName(cmd.DungeonBefore.Name)
+		if index < 0 {
+			return fmt.Errorf("unknown dungeon %s", cmd.DungeonBefore.Name)
+		}
+		if tracker.dungeonStates[index] != *cmd.DungeonBefore {
+			return fmt.Errorf("dungeon %s diverged from the journal", cmd.DungeonBefore.Name)
+		}
+	}
+
+	return nil
+}
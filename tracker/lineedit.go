@@ -0,0 +1,153 @@
+package tracker
+
+import (
+	"unicode"
+)
+
+const inputHistorySize = 100 // per history
+
+// historyKey identifies the history of a kind of text input.
+type historyKey struct {
+	state    inputState
+	category string // hint category name for inputStateTextInput
+}
+
+// setBuf replaces the text input buffer by a copy of buf and moves the cursor
+// to its end.
+func (input *kbInput) setBuf(buf []rune) {
+	input.buf = append([]rune(nil), buf...)
+	input.cursor = len(buf)
+	input.bufChanged()
+}
+
+// insert inserts a rune at the cursor position.
+func (input *kbInput) insert(r rune) {
+	input.buf = append(input.buf, 0)
+	copy(input.buf[input.cursor+1:], input.buf[input.cursor:])
+	input.buf[input.cursor] = r
+	input.cursor++
+	input.bufChanged()
+}
+
+// deleteBack deletes the n runes before the cursor.
+func (input *kbInput) deleteBack(n int) {
+	if n > input.cursor {
+		n = input.cursor
+	}
+	if n <= 0 {
+		return
+	}
+
+	input.buf = append(input.buf[:input.cursor-n], input.buf[input.cursor:]...)
+	input.cursor -= n
+	input.bufChanged()
+}
+
+// wordStart returns the position of the start of the word before the cursor,
+// skipping spaces in between.
+func (input *kbInput) wordStart() int {
+	pos := input.cursor
+	for pos > 0 && unicode.IsSpace(input.buf[pos-1]) {
+		pos--
+	}
+	for pos > 0 && !unicode.IsSpace(input.buf[pos-1]) {
+		pos--
+	}
+
+	return pos
+}
+
+// MoveCursor moves the text input cursor by the given number of runes.
+func (tracker *Tracker) MoveCursor(offset int) {
+	if !tracker.isTyping() {
+		return
+	}
+
+	tracker.input.cursor = clamp(tracker.input.cursor+offset, 0, len(tracker.input.buf))
+}
+
+// CursorToStart moves the text input cursor to the start of the line.
+func (tracker *Tracker) CursorToStart() {
+	if !tracker.isTyping() {
+		return
+	}
+
+	tracker.input.cursor = 0
+}
+
+// CursorToEnd moves the text input cursor to the end of the line.
+func (tracker *Tracker) CursorToEnd() {
+	if !tracker.isTyping() {
+		return
+	}
+
+	tracker.input.cursor = len(tracker.input.buf)
+}
+
+// DeleteWord deletes the word before the text input cursor.
+func (tracker *Tracker) DeleteWord() {
+	if !tracker.isTyping() {
+		return
+	}
+
+	tracker.input.deleteBack(tracker.input.cursor - tracker.input.wordStart())
+}
+
+// historyKey returns the key of the history of the current text input: one per
+// hint category, one for item searches, and one for dungeons.
+func (tracker *Tracker) historyKey() historyKey {
+	key := historyKey{state: tracker.input.state}
+	if key.state == inputStateTextInput {
+		key.category = tracker.hintCategories[tracker.input.textInputFor].Name
+	}
+
+	return key
+}
+
+// addInputHistory records a submitted text input so it can be recalled later
+// from the same kind of input.
+func (tracker *Tracker) addInputHistory(str string) {
+	if str == "" {
+		return
+	}
+
+	key := tracker.historyKey()
+	history := tracker.inputHistory[key]
+	if n := len(history); n > 0 && history[n-1] == str {
+		return
+	}
+
+	history = append(history, str)
+	if len(history) > inputHistorySize {
+		history = history[1:]
+	}
+
+	if tracker.inputHistory == nil {
+		tracker.inputHistory = make(map[historyKey][]string)
+	}
+	tracker.inputHistory[key] = history
+}
+
+// recallHistory replaces the text input with an older (negative offset) or
+// newer entry of its history, moving past the newest entry restores what was
+// being typed.
+func (tracker *Tracker) recallHistory(offset int) {
+	input := &tracker.input
+	history := tracker.inputHistory[tracker.historyKey()]
+	index := clamp(input.historyIndex-offset, 0, len(history))
+	if index == input.historyIndex {
+		return
+	}
+
+	if input.historyIndex == 0 {
+		input.draft = append([]rune(nil), input.buf...)
+	}
+	input.historyIndex = index
+
+	if index == 0 {
+		input.setBuf(input.draft)
+		return
+	}
+
+	input.setBuf([]rune(history[len(history)-index]))
+}
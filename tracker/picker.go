@@ -0,0 +1,187 @@
+package tracker
+
+import (
+	"image"
+	"image/color"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+	"github.com/hajimehoshi/ebiten/text"
+)
+
+const (
+	pickerSize       = 5  // candidates displayed at once
+	pickerLineHeight = 16 // height of a candidate line
+	pickerMargin     = 4
+)
+
+// pickerField is the part of the text input the picker chooses a candidate for.
+type pickerField int
+
+const (
+	pickerFieldNone pickerField = iota
+	pickerFieldSlot
+	pickerFieldLocation
+	pickerFieldItem
+	pickerFieldTemple
+)
+
+// textInputCandidates returns the part of the text input being typed and its
+// candidates in order of preference, the first one being the default match.
+func (tracker *Tracker) textInputCandidates() (pickerField, []string) {
+	if tracker.kbInputStateIs(inputStateItemSearch) {
+		query, _ := tracker.itemSearchQuery()
+		return pickerFieldItem, rankTargets(rankCandidates(query, tracker.itemNames()))
+	}
+
+	if tracker.kbInputStateIs(inputStateTempleInput) {
+		return pickerFieldTemple, tracker.templeCandidates(string(tracker.input.buf))
+	}
+
+	cat := &tracker.hintCategories[tracker.input.textInputFor]
+	str := string(tracker.input.buf)
+
+	if cat.isSlotted() && !tracker.input.editHint {
+		parts := strings.SplitN(str, " ", 2)
+		if len(parts) < 2 {
+			slots := cat.slotCandidates(parts[0])
+			names := make([]string, len(slots))
+			for k, v := range slots {
+				names[k] = cat.Slots[v].Name
+			}
+			return pickerFieldSlot, names
+		}
+		str = parts[1]
+	}
+
+	switch cat.Match {
+	case HintMatchLocation:
+		return pickerFieldLocation, rankTargets(tracker.placeCandidates(str, tracker.locations))
+	case HintMatchItem:
+		return pickerFieldItem, rankTargets(rankCandidates(str, tracker.itemNames()))
+	case HintMatchLocationItem:
+		if cat.isSlotted() {
+			return pickerFieldItem, rankTargets(rankCandidates(str, tracker.itemNames()))
+		}
+
+		split := tracker.splitLocationItem(str)
+		if split.item == "" {
+			return pickerFieldLocation, rankTargets(tracker.placeCandidates(str, tracker.checks))
+		}
+		return pickerFieldItem, rankTargets(rankCandidates(split.itemQuery, tracker.itemNames()))
+	}
+
+	return pickerFieldNone, nil
+}
+
+// pickedCandidate returns the candidate chosen in the picker if the user moved
+// away from the default one.
+func (tracker *Tracker) pickedCandidate() (pickerField, string, bool) {
+	if tracker.input.pick == 0 {
+		return pickerFieldNone, "", false
+	}
+
+	field, candidates := tracker.textInputCandidates()
+	if len(candidates) == 0 {
+		return pickerFieldNone, "", false
+	}
+
+	return field, candidates[tracker.pickIndex(len(candidates))], true
+}
+
+// pickIndex returns the index of the chosen candidate among count ones.
+func (tracker *Tracker) pickIndex(count int) int {
+	return ((tracker.input.pick % count) + count) % count
+}
+
+// applyPick replaces the default match of the hint by the candidate chosen in
+// the picker, slots are picked by splitTextInput.
+func (tracker *Tracker) applyPick(h hint) hint {
+	field, picked, ok := tracker.pickedCandidate()
+	if !ok {
+		return h
+	}
+
+	switch field {
+	case pickerFieldLocation:
+		h.Text = picked
+	case pickerFieldItem:
+		if h.Text == h.Item {
+			h.Text = picked
+		}
+		h.Item = picked
+	}
+
+	return h
+}
+
+// CycleCandidate chooses the next (or previous) fuzzy match of the text input,
+// it is called when the user presses Tab or Shift+Tab. Up and down arrow keys
+// also cycle while the picker is open, see Navigate.
+func (tracker *Tracker) CycleCandidate(offset int) {
+	if !tracker.isTyping() {
+		return
+	}
+
+	tracker.input.pick += offset
+	tracker.input.warning = ""
+}
+
+// isPickerOpen returns true if the picker lists several candidates.
+func (tracker *Tracker) isPickerOpen() bool {
+	if !tracker.isTyping() {
+		return false
+	}
+
+	_, candidates := tracker.textInputCandidates()
+	return len(candidates) > 1
+}
+
+func (tracker *Tracker) drawPicker(screen *ebiten.Image) {
+	if !tracker.isPickerOpen() {
+		return
+	}
+
+	_, candidates := tracker.textInputCandidates()
+
+	selected := tracker.pickIndex(len(candidates))
+	start := (selected / pickerSize) * pickerSize
+	end := start + pickerSize
+	if end > len(candidates) {
+		end = len(candidates)
+	}
+
+	width := 0
+	for _, v := range candidates[start:end] {
+		if w := text.MeasureString(v, tracker.fontSmall).X; w > width {
+			width = w
+		}
+	}
+
+	// Bottom-left of the popup sits right above the input line.
+	bottom := tracker.pos.Add(image.Point{0, 9 * gridSize})
+	rect := image.Rect(
+		bottom.X, bottom.Y-(end-start)*pickerLineHeight-2*pickerMargin,
+		bottom.X+width+2*pickerMargin, bottom.Y,
+	)
+	ebitenutil.DrawRect(
+		screen,
+		float64(rect.Min.X), float64(rect.Min.Y),
+		float64(rect.Dx()), float64(rect.Dy()),
+		color.RGBA{0x00, 0x00, 0x00, 0xD0},
+	)
+
+	for k, v := range candidates[start:end] {
+		y := rect.Min.Y + pickerMargin + k*pickerLineHeight
+		if start+k == selected {
+			ebitenutil.DrawRect(
+				screen,
+				float64(rect.Min.X), float64(y),
+				float64(rect.Dx()), pickerLineHeight,
+				color.RGBA{0xFF, 0xFF, 0xFF, 0x40},
+			)
+		}
+		text.Draw(screen, v, tracker.fontSmall, rect.Min.X+pickerMargin, y+pickerLineHeight-4, color.White)
+	}
+}
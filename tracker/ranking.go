@@ -0,0 +1,204 @@
+package tracker
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/lithammer/fuzzysearch/fuzzy"
+)
+
+// Ranking counts the locations picked for each input prefix so the location
+// the user usually means is preferred over the closest fuzzy match.
+type Ranking map[string]map[string]int // lowercase input prefix -> location -> count
+
+// Ranking returns the current location ranking, to be persisted.
+func (tracker *Tracker) Ranking() Ranking {
+	return tracker.ranking
+}
+
+// SetRanking replaces the location ranking, nil resets it.
+func (tracker *Tracker) SetRanking(ranking Ranking) {
+	if ranking == nil {
+		ranking = Ranking{}
+	}
+
+	tracker.ranking = ranking
+}
+
+// learn records that location was picked for every prefix of input.
+func (ranking Ranking) learn(input, location string) {
+	runes := []rune(normalizeQuery(input))
+	for k := 1; k <= len(runes); k++ {
+		prefix := string(runes[:k])
+		if ranking[prefix] == nil {
+			ranking[prefix] = map[string]int{}
+		}
+		ranking[prefix][location]++
+	}
+}
+
+// best returns the most picked of the candidates for the given input, or an
+// empty string if none was ever picked. Ties keep the candidates order.
+func (ranking Ranking) best(input string, candidates []string) string {
+	counts := ranking[normalizeQuery(input)]
+	var ret string
+	var max int
+	for _, v := range candidates {
+		if counts[v] > max {
+			ret, max = v, counts[v]
+		}
+	}
+
+	return ret
+}
+
+func normalizeQuery(str string) string {
+	return strings.ToLower(strings.Join(strings.Fields(str), " "))
+}
+
+// acronym returns the lowercase initials of a name, eg. "dmc" for "Death
+// Mountain Crater" or "botw" for "Bottom of the Well".
+func acronym(name string) string {
+	var ret []rune
+	for _, word := range strings.Fields(name) {
+		for _, r := range word {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				ret = append(ret, unicode.ToLower(r))
+				break
+			}
+		}
+	}
+
+	return string(ret)
+}
+
+// matchPlace returns the location or check of targets meant by str along with
+// its distance to str, or an empty string if nothing matches.
+func (tracker *Tracker) matchPlace(str string, targets []string) (string, int) {
+	matches := tracker.placeCandidates(str, targets)
+	if len(matches) == 0 {
+		return "", -1
+	}
+
+	return matches[0].Target, matches[0].Distance
+}
+
+// placeCandidates returns the locations or checks of targets matching str in
+// order of preference: alias, most picked location for this input, exact
+// acronyms, then fuzzy matches from the closest.
+func (tracker *Tracker) placeCandidates(str string, targets []string) fuzzy.Ranks {
+	query := normalizeQuery(str)
+	if query == "" { // an empty string matches everything
+		return nil
+	}
+
+	matches := rankCandidates(str, targets)
+	for k := len(targets) - 1; k >= 0; k-- {
+		if acronym(targets[k]) == query {
+			matches = promote(matches, str, targets[k])
+		}
+	}
+
+	if v := tracker.ranking.best(query, rankTargets(matches)); v != "" {
+		matches = promote(matches, str, v)
+	}
+
+	if v, ok := tracker.aliases[query]; ok && contains(targets, v) {
+		matches = promote(matches, str, v)
+	}
+
+	return matches
+}
+
+// isAmbiguousPlace returns true if str is the acronym of several targets and
+// neither an alias nor the ranking tells which one is meant.
+func (tracker *Tracker) isAmbiguousPlace(str string, targets []string) bool {
+	query := normalizeQuery(str)
+	if v, ok := tracker.aliases[query]; ok && contains(targets, v) {
+		return false
+	}
+
+	var matches []string
+	for _, v := range targets {
+		if acronym(v) == query {
+			matches = append(matches, v)
+		}
+	}
+
+	return len(matches) > 1 && tracker.ranking.best(query, matches) == ""
+}
+
+// textInputAmbiguous returns true if the place of the hint being typed is an
+// ambiguous acronym that was not chosen in the picker.
+func (tracker *Tracker) textInputAmbiguous() bool {
+	if !tracker.kbInputStateIs(inputStateTextInput) {
+		return false
+	}
+
+	if field, _ := tracker.textInputCandidates(); field == pickerFieldLocation && tracker.input.pick != 0 {
+		return false
+	}
+
+	cat := &tracker.hintCategories[tracker.input.textInputFor]
+	_, str := tracker.splitTextInput()
+	switch cat.Match {
+	case HintMatchLocation:
+		return tracker.isAmbiguousPlace(str, tracker.locations)
+	case HintMatchLocationItem:
+		if cat.isSlotted() {
+			return false
+		}
+		return tracker.isAmbiguousPlace(tracker.splitLocationItem(str).locationQuery, tracker.checks)
+	default:
+		return false
+	}
+}
+
+// promote moves target to the front of ranks as an exact match.
+func promote(ranks fuzzy.Ranks, source, target string) fuzzy.Ranks {
+	ret := fuzzy.Ranks{{Source: source, Target: target}}
+	for _, v := range ranks {
+		if v.Target != target {
+			ret = append(ret, v)
+		}
+	}
+
+	return ret
+}
+
+func rankTargets(ranks fuzzy.Ranks) []string {
+	ret := make([]string, len(ranks))
+	for k := range ranks {
+		ret[k] = ranks[k].Target
+	}
+
+	return ret
+}
+
+// learnTextInput records the location picked for the current text input.
+func (tracker *Tracker) learnTextInput(h hint) {
+	cat := &tracker.hintCategories[tracker.input.textInputFor]
+	_, str := tracker.splitTextInput()
+
+	switch cat.Match {
+	case HintMatchLocation:
+		if h.Text != str {
+			tracker.ranking.learn(str, h.Text)
+		}
+	case HintMatchLocationItem:
+		if cat.isSlotted() || !contains(tracker.checks, h.Text) {
+			return
+		}
+		tracker.ranking.learn(tracker.splitLocationItem(str).locationQuery, h.Text)
+	}
+}
+
+func contains(list []string, str string) bool {
+	for _, v := range list {
+		if v == str {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,246 @@
+package tracker
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+)
+
+// startHintSelection enters the mode where individual hints can be selected
+// to be edited, deleted, or moved.
+func (tracker *Tracker) startHintSelection() {
+	first := tracker.nextSelectableCategory(-1, 1)
+	if first < 0 {
+		return
+	}
+
+	tracker.input.reset()
+	tracker.input.state = inputStateHintSelection
+	tracker.input.selectedCategory = first
+}
+
+// nextSelectableCategory returns the index of the first category after from
+// in the given direction that has selectable lines, or -1 if there is none.
+func (tracker *Tracker) nextSelectableCategory(from, step int) int {
+	count := len(tracker.hintCategories)
+	for k := 1; k <= count; k++ {
+		index := ((from+k*step)%count + count) % count
+		if tracker.hintCategories[index].selectableHints() > 0 {
+			return index
+		}
+	}
+
+	return -1
+}
+
+// isHintSelected returns true if the hint selection points to an existing hint
+// line.
+func (tracker *Tracker) isHintSelected() bool {
+	index := tracker.input.selectedHint
+	return index >= 0 && index < len(tracker.selectedHintCategory().hints)
+}
+
+func (tracker *Tracker) selectionHandleAction(a action) {
+	switch a {
+	case actionTop:
+		tracker.moveHintSelection(0, -1)
+	case actionBottom:
+		tracker.moveHintSelection(0, 1)
+	case actionLeft:
+		tracker.moveHintSelection(-1, 0)
+	case actionRight:
+		tracker.moveHintSelection(1, 0)
+
+	case actionTopLeft:
+		tracker.moveSelectedHint(-1)
+	case actionTopRight:
+		tracker.moveSelectedHint(1)
+
+	case actionBottomRight:
+		tracker.cycleHintStatus(tracker.input.selectedCategory, tracker.input.selectedHint, true)
+	case actionBottomLeft:
+		tracker.cycleHintStatus(tracker.input.selectedCategory, tracker.input.selectedHint, false)
+
+	case actionMiddle, actionSubmit:
+		tracker.editSelectedHint()
+	case actionDowngradeNext:
+		tracker.deleteSelectedHint()
+	case actionCancel:
+		tracker.input.reset()
+
+	case actionUndo:
+		tracker.undo()
+	case actionRedo:
+		tracker.redo()
+	}
+}
+
+// Navigate moves the hint selection, or the text input cursor and either the
+// picker candidate or the history, it is called when the user presses an
+// arrow key. Up and down cycle the candidates while the picker is open unless
+// the user is already going through the history.
+func (tracker *Tracker) Navigate(dx, dy int) {
+	switch {
+	case tracker.kbInputStateIs(inputStateHintSelection):
+		tracker.moveHintSelection(dx, dy)
+	case tracker.isTyping():
+		tracker.MoveCursor(dx)
+		if dy == 0 {
+			return
+		}
+
+		if tracker.input.historyIndex == 0 && tracker.isPickerOpen() {
+			tracker.CycleCandidate(dy)
+		} else {
+			tracker.recallHistory(dy)
+		}
+	}
+}
+
+func (tracker *Tracker) selectedHintCategory() *hintCategory {
+	return &tracker.hintCategories[tracker.input.selectedCategory]
+}
+
+// selectableHints returns the number of lines that can be selected in a
+// category, ie. all slots or all hints plus an empty line to add one.
+func (cat *hintCategory) selectableHints() int {
+	if cat.isSlotted() || len(cat.hints) >= cat.Capacity {
+		return len(cat.hints)
+	}
+
+	return len(cat.hints) + 1
+}
+
+// moveHintSelection moves the selection to the previous/next category having
+// selectable lines and by dy lines. The selection mode is left if no line can
+// be selected.
+func (tracker *Tracker) moveHintSelection(dx, dy int) {
+	if dx != 0 || tracker.selectedHintCategory().selectableHints() == 0 {
+		step := 1
+		if dx < 0 {
+			step = -1
+		}
+
+		category := tracker.nextSelectableCategory(tracker.input.selectedCategory, step)
+		if category < 0 {
+			tracker.input.reset()
+			return
+		}
+		tracker.input.selectedCategory = category
+	}
+
+	tracker.input.selectedHint = clamp(
+		tracker.input.selectedHint+dy,
+		0, tracker.selectedHintCategory().selectableHints()-1,
+	)
+}
+
+// moveSelectedHint swaps the selected hint with the one offset lines below.
+func (tracker *Tracker) moveSelectedHint(offset int) {
+	cat := tracker.selectedHintCategory()
+	from := tracker.input.selectedHint
+	to := from + offset
+	if !tracker.isHintSelected() || to < 0 || to >= len(cat.hints) {
+		return
+	}
+
+	tracker.updateHints(cat.Name, func() bool {
+		cat.hints[from], cat.hints[to] = cat.hints[to], cat.hints[from]
+		return true
+	})
+	tracker.input.selectedHint = to
+}
+
+func (tracker *Tracker) deleteSelectedHint() {
+	cat := tracker.selectedHintCategory()
+	index := tracker.input.selectedHint
+	if !tracker.isHintSelected() || cat.hints[index].Text == "" {
+		return
+	}
+
+	tracker.updateHints(cat.Name, func() bool {
+		if cat.isSlotted() {
+			cat.hints[index] = hint{}
+		} else {
+			cat.hints = append(cat.hints[:index], cat.hints[index+1:]...)
+		}
+		return true
+	})
+	tracker.moveHintSelection(0, 0)
+}
+
+// editSelectedHint starts a text input prefilled with the selected hint, or a
+// regular text input for the category if the selected line is empty.
+func (tracker *Tracker) editSelectedHint() {
+	cat := tracker.selectedHintCategory()
+	index := tracker.input.selectedHint
+	if index < 0 || index >= cat.selectableHints() {
+		return
+	}
+
+	tracker.input.state = inputStateTextInput
+	tracker.input.textInputFor = tracker.input.selectedCategory
+	if index >= len(cat.hints) {
+		return
+	}
+
+	tracker.input.editHint = true
+	tracker.input.setBuf([]rune(cat.hints[index].input()))
+}
+
+// submitHintEdit replaces the selected hint with the text input and returns
+// to the hint selection.
+func (tracker *Tracker) submitHintEdit() {
+	cat := tracker.selectedHintCategory()
+	index := tracker.input.selectedHint
+	_, h := tracker.parseTextInput()
+	if h.Text != "" && !tracker.confirmHint(tracker.input.selectedCategory, index, h, index) {
+		return
+	}
+
+	if h.Text != "" && tracker.isHintSelected() &&
+		(cat.hints[index].Text != h.Text || cat.hints[index].Item != h.Item) {
+		tracker.updateHints(cat.Name, func() bool {
+			cat.hints[index].Text = h.Text
+			cat.hints[index].Item = h.Item
+			return true
+		})
+		tracker.learnTextInput(h)
+		tracker.addInputHistory(string(tracker.input.buf))
+	}
+
+	tracker.input.endHintEdit()
+}
+
+func (tracker *Tracker) drawHintSelection(screen *ebiten.Image) {
+	if !tracker.kbInputStateIs(inputStateHintSelection) && !tracker.input.editHint {
+		return
+	}
+
+	rect, ok := tracker.selectedHintCategory().lineRect(tracker.input.selectedHint)
+	if !ok {
+		return
+	}
+
+	rect = rect.Add(tracker.hintPos)
+	ebitenutil.DrawRect(
+		screen,
+		float64(rect.Min.X), float64(rect.Min.Y),
+		float64(rect.Dx()), float64(rect.Dy()),
+		color.RGBA{0x00, 0x00, 0x00, 0x30},
+	)
+}
+
+// hintSelectionText returns the text to display on the input line while
+// selecting a hint.
+func (tracker *Tracker) hintSelectionText() string {
+	cat := tracker.selectedHintCategory()
+	index := tracker.input.selectedHint
+	if cat.isSlotted() && index >= 0 && index < len(cat.Slots) {
+		return fmt.Sprintf("%s: %s", cat.Name, cat.Slots[index].Name)
+	}
+
+	return fmt.Sprintf("%s #%d", cat.Name, index+1)
+}
@@ -0,0 +1,139 @@
+package tracker
+
+import "log"
+
+// State holds everything the user tracked, it is meant to be serialized to
+// restore a session after a crash.
+type State struct {
+	snapshot
+
+	UndoStack []command
+	RedoStack []command
+}
+
+// snapshot is the tracked part of the state, without the history.
+type snapshot struct {
+	Items []ItemState
+	Hints map[string][]hint // by category name
+
+	// Slot names of the slotted hint categories by category name, slotted
+	// hints are restored by slot name rather than position.
+	Slots map[string][]string `json:",omitempty"`
+
+	HintProfile string `json:",omitempty"` // restored if still configured, empty for none
+
+	Dungeons []DungeonState `json:",omitempty"`
+}
+
+// ItemState holds the mutable part of an Item, identified by its name.
+type ItemState struct {
+	Name         string
+	UpgradeIndex int    `json:",omitempty"`
+	Temple       string `json:",omitempty"` // dungeon name
+	Count        int    `json:",omitempty"`
+	Enabled      bool   `json:",omitempty"`
+}
+
+// State returns a copy of the current tracker state.
+func (tracker *Tracker) State() State {
+	return State{
+		snapshot:  tracker.snapshot(),
+		UndoStack: append([]command(nil), tracker.undoStack...),
+		RedoStack: append([]command(nil), tracker.redoStack...),
+	}
+}
+
+// snapshot returns a copy of the tracked state.
+func (tracker *Tracker) snapshot() snapshot {
+	ret := snapshot{
+		Items: make([]ItemState, len(tracker.items)),
+		Hints: make(map[string][]hint, len(tracker.hintCategories)),
+		Slots: make(map[string][]string),
+
+		HintProfile: tracker.hintProfile,
+	}
+
+	for k := range tracker.items {
+		ret.Items[k] = tracker.items[k].State()
+	}
+
+	for _, v := range tracker.hintCategories {
+		ret.Hints[v.Name] = tracker.hints(v.Name)
+		if v.isSlotted() {
+			ret.Slots[v.Name] = v.slotNames()
+		}
+	}
+
+	ret.Dungeons = append([]DungeonState(nil), tracker.dungeonStates...)
+
+	return ret
+}
+
+// SetState replaces the current tracker state with the given one. Items are
+// matched by name, unknown items are ignored. The current input is dropped.
+func (tracker *Tracker) SetState(state State) {
+	tracker.input.reset()
+	tracker.setSnapshot(state.snapshot)
+	tracker.undoStack = append([]command(nil), state.UndoStack...)
+	tracker.redoStack = append([]command(nil), state.RedoStack...)
+}
+
+// setSnapshot replaces the tracked state, leaving the history untouched.
+func (tracker *Tracker) setSnapshot(state snapshot) {
+	tracker.setHintProfile(state.HintProfile)
+
+	for _, v := range state.Items {
+		index := tracker.getItemIndexByName(v.Name)
+		if index < 0 {
+			log.Printf("warning: unknown item in state: %s", v.Name)
+			continue
+		}
+
+		tracker.items[index].SetState(v)
+		if v.Temple != "" && tracker.getDungeonIndex(v.Temple) < 0 {
+			log.Printf("warning: unknown dungeon for item %s: %s", v.Name, v.Temple)
+			tracker.items[index].temple = ""
+		}
+	}
+
+	for _, v := range tracker.hintCategories {
+		tracker.setHints(v.Name, nil, nil)
+	}
+	for name, hints := range state.Hints {
+		tracker.setHints(name, hints, state.Slots[name])
+	}
+
+	tracker.resetDungeonStates()
+	for _, v := range state.Dungeons {
+		tracker.setDungeonState(v)
+	}
+}
+
+// applyStartingItems enables and sets the state of all configured starting
+// items without recording it in the history.
+func (tracker *Tracker) applyStartingItems() {
+	for _, v := range tracker.startingItems {
+		index := tracker.getItemIndexByName(v.Name)
+		if index < 0 {
+			log.Printf("warning: unknown starting item: %s", v.Name)
+			continue
+		}
+
+		var temple string
+		if v.Temple != "" {
+			if dungeon := tracker.getDungeonIndex(v.Temple); dungeon >= 0 {
+				temple = tracker.dungeons[dungeon].Name
+			} else {
+				log.Printf("warning: unknown dungeon for starting item %s: %s", v.Name, v.Temple)
+			}
+		}
+
+		tracker.items[index].SetState(ItemState{
+			Name:         v.Name,
+			UpgradeIndex: v.Upgrade,
+			Temple:       temple,
+			Count:        v.Count,
+			Enabled:      true,
+		})
+	}
+}
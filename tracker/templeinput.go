@@ -0,0 +1,53 @@
+package tracker
+
+import (
+	"log"
+)
+
+// templeNames returns the names of the dungeons that can be assigned to the
+// reward being edited.
+func (tracker *Tracker) templeNames() []string {
+	ret := make([]string, 0, len(tracker.dungeons))
+	for k, v := range tracker.dungeons {
+		if tracker.isDungeonAvailable(k, tracker.input.templeItem) {
+			ret = append(ret, v.Name)
+		}
+	}
+
+	return ret
+}
+
+// templeCandidates returns the dungeon names matching str in order of
+// preference.
+func (tracker *Tracker) templeCandidates(str string) []string {
+	return rankTargets(tracker.placeCandidates(str, tracker.templeNames()))
+}
+
+// templeInputMatch returns the index of the dungeon the text input matches,
+// taking the picker into account, or -1 if nothing matches.
+func (tracker *Tracker) templeInputMatch() int {
+	if _, picked, ok := tracker.pickedCandidate(); ok {
+		return tracker.getDungeonIndex(picked)
+	}
+
+	match, _ := tracker.matchPlace(string(tracker.input.buf), tracker.templeNames())
+	return tracker.getDungeonIndex(match)
+}
+
+// submitTempleInput assigns the matched dungeon to the selected reward, an
+// empty text input removes it.
+func (tracker *Tracker) submitTempleInput() {
+	defer tracker.input.reset()
+
+	index := -1
+	if len(tracker.input.buf) > 0 {
+		index = tracker.templeInputMatch()
+		if index < 0 {
+			log.Printf("warning: no dungeon matching %s", string(tracker.input.buf))
+			return
+		}
+		tracker.addInputHistory(string(tracker.input.buf))
+	}
+
+	tracker.setTemple(tracker.input.templeItem, index)
+}
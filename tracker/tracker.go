@@ -6,6 +6,7 @@ import (
 	"image"
 	"image/color"
 	"log"
+	"path/filepath"
 	"strconv"
 
 	"github.com/golang/freetype/truetype"
@@ -22,6 +23,9 @@ type Tracker struct {
 	hintPos  image.Point
 	hintSize image.Point
 
+	dungeonPos  image.Point
+	dungeonSize image.Point
+
 	background     *ebiten.Image
 	backgroundHelp *ebiten.Image
 	font           font.Face
@@ -29,18 +33,32 @@ type Tracker struct {
 	sheetDisabled  *ebiten.Image
 	sheetEnabled   *ebiten.Image
 
-	items       []Item
-	zoneItemMap ZoneItemMap
-	locations   []string
-	input       kbInput
-
-	woths     []string
-	barrens   []string
-	always    [7]string // skull, bigg, 30, 40, 50, OOT, frogs 2
-	sometimes []string  // freeform input
-
-	undoStack []undoStackEntry
-	redoStack []undoStackEntry
+	items         []Item
+	startingItems []StartingItem
+	zoneItemMap   ZoneItemMap
+	locations     []string
+	checks        []string
+	runes         map[rune]action
+
+	dungeonLocations []string
+	dungeons         []Dungeon
+	dungeonRewards   []string          // names of the items accepting a dungeon
+	aliases          map[string]string // lowercase alias -> location or check
+	ranking          Ranking
+	input            kbInput
+
+	hintCategories []hintCategory
+	hintConfig     []HintCategory // categories before applying the profile
+	hintProfiles   map[string]HintProfile
+	hintProfile    string // key of hintProfiles in use, if any
+	panelDungeons  []PanelDungeon
+	dungeonStates  []DungeonState          // by panelDungeons index
+	inputHistory   map[historyKey][]string // submitted text inputs, oldest first
+
+	undoStack []command
+	redoStack []command
+
+	journal Journal
 }
 
 const (
@@ -50,19 +68,39 @@ const (
 
 type ZoneItemMap [9][9]string
 
-func New(
-	dimensions image.Rectangle,
-	hintDimensions image.Rectangle,
-	items []Item,
-	zoneItemMap ZoneItemMap,
-	locations []string,
-) (*Tracker, error) {
-	background, _, err := ebitenutil.NewImageFromFile("assets/background.png", ebiten.FilterDefault)
+// Config holds the user-configurable part of the tracker.
+type Config struct {
+	Dimensions     image.Rectangle
+	HintDimensions image.Rectangle
+	// Dungeon panel, hidden if there are no PanelDungeons.
+	DungeonDimensions image.Rectangle
+	PanelDungeons     []PanelDungeon
+	Items             []Item
+	StartingItems     []StartingItem
+	ZoneItemMap       ZoneItemMap
+	Locations         []string
+	Checks            []string // defaults to Locations
+	// Locations that are dungeons, the others are overworld.
+	DungeonLocations []string
+	// Alternative names of locations and checks, eg. "gc" for "Goron City".
+	LocationAliases map[string]string
+	// Dungeons rewards can be found in, and the items that are rewards.
+	Dungeons       []Dungeon
+	DungeonRewards []string
+	HintCategories []HintCategory
+	// Hint distributions by name, HintProfile is the one in use if any.
+	HintProfiles map[string]HintProfile
+	HintProfile  string
+	Runes        map[string]Action // defaults to DefaultRunes
+}
+
+func New(assetsDir string, cfg Config) (*Tracker, error) {
+	background, _, err := ebitenutil.NewImageFromFile(filepath.Join(assetsDir, "background.png"), ebiten.FilterDefault)
 	if err != nil {
 		return nil, err
 	}
 
-	backgroundHelp, _, err := ebitenutil.NewImageFromFile("assets/background-help.png", ebiten.FilterDefault)
+	backgroundHelp, _, err := ebitenutil.NewImageFromFile(filepath.Join(assetsDir, "background-help.png"), ebiten.FilterDefault)
 	if err != nil {
 		return nil, err
 	}
@@ -72,24 +110,17 @@ func New(
 		return nil, err
 	}
 
-	sheetDisabled, _, err := ebitenutil.NewImageFromFile("assets/items-disabled.png", ebiten.FilterDefault)
+	sheetDisabled, _, err := ebitenutil.NewImageFromFile(filepath.Join(assetsDir, "items-disabled.png"), ebiten.FilterDefault)
 	if err != nil {
 		return nil, err
 	}
 
-	sheetEnabled, _, err := ebitenutil.NewImageFromFile("assets/items.png", ebiten.FilterDefault)
+	sheetEnabled, _, err := ebitenutil.NewImageFromFile(filepath.Join(assetsDir, "items.png"), ebiten.FilterDefault)
 	if err != nil {
 		return nil, err
 	}
 
 	tracker := &Tracker{
-		pos:            dimensions.Min,
-		size:           dimensions.Size(),
-		hintPos:        hintDimensions.Min,
-		hintSize:       hintDimensions.Size(),
-		items:          items,
-		locations:      locations,
-		zoneItemMap:    zoneItemMap,
 		background:     background,
 		backgroundHelp: backgroundHelp,
 		sheetDisabled:  sheetDisabled,
@@ -102,11 +133,11 @@ func New(
 			Size:    templeFontSize,
 			Hinting: font.HintingFull,
 		}),
+		ranking: Ranking{},
 	}
 
-	tracker.changeItem(tracker.getItemIndexByName("Gold Skulltula Token"), true)
-	tracker.changeItem(tracker.getItemIndexByName("Kokiri Tunic"), true)
-	tracker.changeItem(tracker.getItemIndexByName("Kokiri Boots"), true)
+	tracker.configure(cfg)
+	tracker.applyStartingItems()
 
 	return tracker, nil
 }
@@ -163,8 +194,38 @@ func (tracker *Tracker) getItemIndexByName(name string) int {
 	return -1
 }
 
-// ClickLeft upgrades the item under the given point.
+// getItemIndexByAnyName returns the index of the item having the given name or
+// progression name (eg. "Longshot"), along with the index in its progression
+// or -1 if it matched the item name.
+func (tracker *Tracker) getItemIndexByAnyName(name string) (int, int) {
+	if index := tracker.getItemIndexByName(name); index >= 0 {
+		return index, -1
+	}
+
+	for k := range tracker.items {
+		for i := range tracker.items[k].ItemProgression {
+			if tracker.items[k].ItemProgression[i].Name == name {
+				return k, i
+			}
+		}
+	}
+
+	return -1, -1
+}
+
+// ClickLeft upgrades the item under the given point, cycles the status of
+// the hint under it, or sets the dungeon field under it.
 func (tracker *Tracker) ClickLeft(x, y int) {
+	if cat, index, ok := tracker.hintAt(x, y); ok {
+		tracker.cycleHintStatus(cat, index, true)
+		return
+	}
+
+	if index, field, ok := tracker.dungeonFieldAt(x, y); ok {
+		tracker.changeDungeon(index, field, true)
+		return
+	}
+
 	i := tracker.getItemIndexByPos(x, y)
 	if i < 0 {
 		return
@@ -173,8 +234,19 @@ func (tracker *Tracker) ClickLeft(x, y int) {
 	tracker.changeItem(i, true)
 }
 
-// ClickRight downgrades the item under the given point.
+// ClickRight downgrades the item under the given point, cycles back the
+// status of the hint under it, or clears the dungeon field under it.
 func (tracker *Tracker) ClickRight(x, y int) {
+	if cat, index, ok := tracker.hintAt(x, y); ok {
+		tracker.cycleHintStatus(cat, index, false)
+		return
+	}
+
+	if index, field, ok := tracker.dungeonFieldAt(x, y); ok {
+		tracker.changeDungeon(index, field, false)
+		return
+	}
+
 	i := tracker.getItemIndexByPos(x, y)
 	if i < 0 {
 		return
@@ -184,27 +256,28 @@ func (tracker *Tracker) ClickRight(x, y int) {
 }
 
 func (tracker *Tracker) changeItem(itemIndex int, isUpgrade bool) {
-	var fn func() bool
-	if isUpgrade {
-		fn = tracker.items[itemIndex].Upgrade
-	} else {
-		fn = tracker.items[itemIndex].Downgrade
-	}
-
-	if fn() {
-		tracker.appendToUndoStack(itemIndex, isUpgrade)
-	}
+	tracker.updateItem(itemIndex, func(item *Item) bool {
+		if isUpgrade {
+			return item.Upgrade()
+		}
+		return item.Downgrade()
+	})
 }
 
 func (tracker *Tracker) Wheel(x, y int, up bool) {
+	if index, field, ok := tracker.dungeonFieldAt(x, y); ok {
+		tracker.changeDungeon(index, field, up)
+		return
+	}
+
 	i := tracker.getItemIndexByPos(x, y)
 	if i < 0 {
 		return
 	}
 
 	switch {
-	case tracker.items[i].IsMedallion:
-		tracker.items[i].CycleTemple(up)
+	case tracker.isDungeonReward(i):
+		tracker.cycleTemple(i, up)
 	default:
 		if up {
 			tracker.ClickLeft(x, y)
@@ -246,11 +319,15 @@ func (tracker *Tracker) Draw(screen *ebiten.Image) {
 	// Do two loops to avoid texture switches.
 	drawState(false, tracker.sheetDisabled)
 	drawState(true, tracker.sheetEnabled)
+	tracker.drawHintedItem(screen)
 
 	tracker.drawTemples(screen)
 	tracker.drawCapacities(screen)
 	tracker.drawInputState(screen)
+	tracker.drawPicker(screen)
+	tracker.drawHintSelection(screen)
 	tracker.drawHints(screen)
+	tracker.drawDungeons(screen)
 }
 
 func (tracker *Tracker) drawActiveItemSlot(screen *ebiten.Image, slot int) {
@@ -292,13 +369,14 @@ func (tracker *Tracker) drawActiveItemSlot(screen *ebiten.Image, slot int) {
 
 func (tracker *Tracker) drawTemples(screen *ebiten.Image) {
 	for k := range tracker.items {
-		if !tracker.items[k].IsMedallion {
+		index := tracker.getDungeonIndex(tracker.items[k].temple)
+		if index < 0 || !tracker.isDungeonReward(k) {
 			continue
 		}
 
 		rect := tracker.items[k].Rect()
 		x, y := rect.Min.X, rect.Max.Y
-		text.Draw(screen, tracker.items[k].TempleText(), tracker.fontSmall, x, y, color.White)
+		text.Draw(screen, tracker.dungeons[index].label(), tracker.fontSmall, x, y, color.White)
 	}
 }
 
@@ -336,13 +414,70 @@ func (tracker *Tracker) drawCapacities(screen *ebiten.Image) {
 	}
 }
 
-func (tracker *Tracker) Reset(items []Item, zoneItemMap ZoneItemMap) {
-	tracker.items = items
-	tracker.zoneItemMap = zoneItemMap
+func (tracker *Tracker) configure(cfg Config) {
+	tracker.pos = cfg.Dimensions.Min
+	tracker.size = cfg.Dimensions.Size()
+	tracker.hintPos = cfg.HintDimensions.Min
+	tracker.hintSize = cfg.HintDimensions.Size()
+	tracker.dungeonPos = cfg.DungeonDimensions.Min
+	tracker.dungeonSize = cfg.DungeonDimensions.Size()
+	tracker.items = cfg.Items
+	tracker.startingItems = cfg.StartingItems
+	tracker.zoneItemMap = cfg.ZoneItemMap
+	tracker.locations = cfg.Locations
+	tracker.checks = cfg.Checks
+	if cfg.Runes == nil {
+		cfg.Runes = DefaultRunes()
+	}
+	runes, err := parseRunes(cfg.Runes)
+	if err != nil {
+		log.Printf("warning: %s, using default rune bindings", err)
+		runes, _ = parseRunes(DefaultRunes())
+	}
+	tracker.runes = runes
+	tracker.dungeonLocations = cfg.DungeonLocations
+	tracker.dungeons = cfg.Dungeons
+	tracker.dungeonRewards = cfg.DungeonRewards
+	tracker.aliases = make(map[string]string, len(cfg.LocationAliases))
+	for k, v := range cfg.LocationAliases {
+		tracker.aliases[normalizeQuery(k)] = v
+	}
+	if len(tracker.checks) == 0 {
+		tracker.checks = cfg.Locations
+	}
+	tracker.hintConfig = cfg.HintCategories
+	tracker.hintProfiles = cfg.HintProfiles
+	tracker.hintProfile = cfg.HintProfile
+	tracker.hintCategories = newHintCategories(cfg.HintCategories, cfg.HintProfiles[cfg.HintProfile])
+	tracker.panelDungeons = cfg.PanelDungeons
+	tracker.resetDungeonStates()
+}
+
+// Reconfigure applies a new configuration while keeping the tracked state,
+// items and hint slots are matched by name. The current input is dropped as it
+// refers to the previous configuration.
+func (tracker *Tracker) Reconfigure(cfg Config) {
+	state := tracker.State()
+	tracker.configure(cfg)
+	state.HintProfile = tracker.hintProfile // the new one prevails
+	tracker.SetState(state)
+}
+
+// Reset applies a new configuration and starts tracking from scratch. The
+// reset itself is the only entry of the new history so it can be undone,
+// undoing it also brings back the previous hint profile.
+func (tracker *Tracker) Reset(cfg Config) {
+	before := tracker.snapshot()
+	tracker.configure(cfg)
+	tracker.input.reset()
 	tracker.undoStack = tracker.undoStack[:0]
 	tracker.redoStack = tracker.redoStack[:0]
-	tracker.woths = tracker.woths[:0]
-	tracker.barrens = tracker.barrens[:0]
-	tracker.sometimes = tracker.sometimes[:0]
-	tracker.always = [7]string{}
+	tracker.applyStartingItems()
+
+	after := tracker.snapshot()
+	tracker.commit(command{
+		Kind:        commandReset,
+		ResetBefore: &before,
+		ResetAfter:  &after,
+	})
 }
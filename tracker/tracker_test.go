@@ -0,0 +1,72 @@
+package tracker
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+// testConfig returns a small configuration using the given hint profile:
+// "wide" raises the WotH capacity, "moved" reorders and replaces the Always
+// slots.
+func testConfig(profile string) Config {
+	return Config{
+		DungeonDimensions: image.Rect(0, 0, 294, 4*DungeonLineHeight),
+		PanelDungeons: []PanelDungeon{
+			{Name: "Forest Temple", Keys: 5, MQKeys: 6, BossKey: true, MapCompass: true},
+			{Name: "Ice Cavern", MapCompass: true},
+		},
+		Items: []Item{
+			{Name: "Bow", CapacityProgression: []int{30, 40, 50}},
+			{Name: "Hookshot", ItemProgression: []Item{{Name: "Hookshot"}, {Name: "Longshot"}}},
+			{Name: "Gold Skulltula Token", CountMax: 100, CountStep: 1},
+			{Name: "Forest Medallion"},
+		},
+		Locations:        []string{"Kakariko Village", "Lost Woods", "Forest Temple", "Ice Cavern"},
+		DungeonLocations: []string{"Forest Temple", "Ice Cavern"},
+		Dungeons:         DefaultDungeons(),
+		DungeonRewards:   []string{"Forest Medallion"},
+		HintCategories: []HintCategory{
+			{Name: "WotH", Hotkey: "w", Match: HintMatchLocation, Capacity: 2},
+			{
+				Name: "Barren", Hotkey: "b", Match: HintMatchLocation,
+				LocationKind: LocationKindOverworld, Capacity: 2,
+			},
+			{
+				Name: "Always", Hotkey: "a", Match: HintMatchLocationItem,
+				Slots: []HintSlot{{Name: "Skull Mask"}, {Name: "Biggoron"}, {Name: "Frogs"}},
+			},
+		},
+		HintProfiles: map[string]HintProfile{
+			"wide": {"WotH": {Capacity: 4}},
+			"moved": {"Always": {Slots: []HintSlot{
+				{Name: "Frogs"}, {Name: "Skull Mask"}, {Name: "Ocarina"},
+			}}},
+		},
+		HintProfile: profile,
+	}
+}
+
+func newTestTracker(profile string) *Tracker {
+	tracker := &Tracker{ranking: Ranking{}}
+	tracker.configure(testConfig(profile))
+
+	return tracker
+}
+
+func TestReconfigureKeepsState(t *testing.T) {
+	tracker := newTestTracker("")
+	tracker.changeItem(0, true)
+	tracker.changeItem(0, true)
+	tracker.addHint(0, -1, hint{Text: "Lost Woods"})
+	tracker.changeDungeon(0, dungeonFieldKeys, true)
+	before := tracker.snapshot()
+
+	tracker.Reconfigure(testConfig(""))
+	if after := tracker.snapshot(); !reflect.DeepEqual(before, after) {
+		t.Errorf("state changed on reconfigure:\n%+v\n%+v", before, after)
+	}
+	if len(tracker.undoStack) != 4 {
+		t.Errorf("expected the history to be kept, got %d commands", len(tracker.undoStack))
+	}
+}
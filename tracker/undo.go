@@ -2,68 +2,158 @@ package tracker
 
 import "log"
 
-// undoStackEntry represents an action (upgrade/downgrade) that happened on an item.
-type undoStackEntry struct {
-	hintText          string
-	hintType          hintType
-	itemIndex         int
-	isHint, isUpgrade bool
+type commandKind string
+
+const (
+	commandItem  commandKind = "item"  // any change to a single item
+	commandHints commandKind = "hints" // any change to a list of hints
+
+	commandDungeon commandKind = "dungeon" // any change to a single dungeon
+	commandReset   commandKind = "reset"   // whole tracked state on reset
+)
+
+// command is a reversible change of the tracker state. It holds the affected
+// part of the state both before and after the change so undoing and redoing
+// it is always exact.
+type command struct {
+	Kind commandKind
+
+	ItemBefore, ItemAfter ItemState
+
+	Hint                    string   `json:",omitempty"` // category name
+	HintsBefore, HintsAfter []hint   `json:",omitempty"`
+	HintSlots               []string `json:",omitempty"` // slot names of the category
+
+	DungeonBefore, DungeonAfter *DungeonState `json:",omitempty"`
+
+	ResetBefore, ResetAfter *snapshot `json:",omitempty"`
 }
 
-func (tracker *Tracker) appendHintToUndoStack(t hintType, str string) {
-	// If we were back in time, discard and replace history.
-	if len(tracker.redoStack) > 0 {
-		tracker.redoStack = nil
+// updateItem applies fn to an item and records the change in the history.
+// fn must return false if the item was not affected.
+func (tracker *Tracker) updateItem(itemIndex int, fn func(*Item) bool) bool {
+	before := tracker.items[itemIndex].State()
+	if !fn(&tracker.items[itemIndex]) {
+		return false
 	}
 
-	tracker.undoStack = append(tracker.undoStack, undoStackEntry{
-		isHint:   true,
-		hintType: t,
-		hintText: str,
+	tracker.commit(command{
+		Kind:       commandItem,
+		ItemBefore: before,
+		ItemAfter:  tracker.items[itemIndex].State(),
 	})
+
+	return true
 }
 
-func (tracker *Tracker) appendToUndoStack(itemIndex int, isUpgrade bool) {
-	// If we were back in time, discard and replace history.
-	if len(tracker.redoStack) > 0 {
-		tracker.redoStack = nil
+// updateHints applies fn to the tracker and records the change of the hints of
+// the given category in the history. fn must return false if the hints were
+// not affected.
+func (tracker *Tracker) updateHints(category string, fn func() bool) bool {
+	before := tracker.hints(category)
+	if !fn() {
+		return false
 	}
 
-	tracker.undoStack = append(tracker.undoStack, undoStackEntry{
-		itemIndex: itemIndex,
-		isUpgrade: isUpgrade,
+	tracker.commit(command{
+		Kind:        commandHints,
+		Hint:        category,
+		HintsBefore: before,
+		HintsAfter:  tracker.hints(category),
+		HintSlots:   tracker.hintSlots(category),
 	})
+
+	return true
+}
+
+// updateDungeon applies fn to the state of a panel dungeon and records the
+// change in the history. fn must return false if the dungeon was not affected.
+func (tracker *Tracker) updateDungeon(index int, fn func(*DungeonState) bool) bool {
+	before := tracker.dungeonStates[index]
+	if !fn(&tracker.dungeonStates[index]) {
+		return false
+	}
+
+	after := tracker.dungeonStates[index]
+	tracker.commit(command{
+		Kind:          commandDungeon,
+		DungeonBefore: &before,
+		DungeonAfter:  &after,
+	})
+
+	return true
+}
+
+// commit appends an already applied command to the history.
+func (tracker *Tracker) commit(cmd command) {
+	// If we were back in time, discard and replace history.
+	tracker.redoStack = nil
+	tracker.undoStack = append(tracker.undoStack, cmd)
+	tracker.record(Event{Kind: EventDo, Command: &cmd})
+}
+
+// apply sets the state held by the command, after the change if after is
+// true, before otherwise.
+func (tracker *Tracker) apply(cmd command, after bool) {
+	switch cmd.Kind {
+	case commandItem:
+		state := cmd.ItemBefore
+		if after {
+			state = cmd.ItemAfter
+		}
+
+		index := tracker.getItemIndexByName(state.Name)
+		if index < 0 {
+			log.Printf("warning: unknown item in history: %s", state.Name)
+			return
+		}
+		tracker.items[index].SetState(state)
+
+	case commandHints:
+		hints := cmd.HintsBefore
+		if after {
+			hints = cmd.HintsAfter
+		}
+		tracker.setHints(cmd.Hint, hints, cmd.HintSlots)
+
+	case commandDungeon:
+		state := cmd.DungeonBefore
+		if after {
+			state = cmd.DungeonAfter
+		}
+		if state == nil {
+			log.Printf("warning: dungeon command without state in history")
+			return
+		}
+		tracker.setDungeonState(*state)
+
+	case commandReset:
+		state := cmd.ResetBefore
+		if after {
+			state = cmd.ResetAfter
+		}
+		if state == nil {
+			log.Printf("warning: reset command without state in history")
+			return
+		}
+		tracker.setSnapshot(*state)
+
+	default:
+		log.Printf("warning: unknown command kind in history: %s", cmd.Kind)
+	}
 }
+
 func (tracker *Tracker) undo() {
 	if len(tracker.undoStack) == 0 {
 		log.Printf("no action to undo")
 		return
 	}
 
-	entry := tracker.undoStack[len(tracker.undoStack)-1]
+	cmd := tracker.undoStack[len(tracker.undoStack)-1]
 	tracker.undoStack = tracker.undoStack[:len(tracker.undoStack)-1]
-	tracker.redoStack = append(tracker.redoStack, entry)
-
-	if entry.isHint {
-		switch entry.hintType {
-		case hintTypeWOTH:
-			tracker.woths = tracker.woths[:len(tracker.woths)-1]
-		case hintTypeBarren:
-			tracker.barrens = tracker.barrens[:len(tracker.barrens)-1]
-		case hintTypeSometimes:
-			tracker.sometimes = tracker.sometimes[:len(tracker.sometimes)-1]
-		case hintTypeAlways:
-			index, _ := parseAlways(entry.hintText)
-			tracker.setAlways(index, "")
-		}
-		return
-	}
-
-	if entry.isUpgrade {
-		tracker.items[entry.itemIndex].Downgrade()
-	} else {
-		tracker.items[entry.itemIndex].Upgrade()
-	}
+	tracker.redoStack = append(tracker.redoStack, cmd)
+	tracker.apply(cmd, false)
+	tracker.record(Event{Kind: EventUndo})
 }
 
 func (tracker *Tracker) redo() {
@@ -72,27 +162,9 @@ func (tracker *Tracker) redo() {
 		return
 	}
 
-	entry := tracker.redoStack[len(tracker.redoStack)-1]
+	cmd := tracker.redoStack[len(tracker.redoStack)-1]
 	tracker.redoStack = tracker.redoStack[:len(tracker.redoStack)-1]
-	tracker.undoStack = append(tracker.undoStack, entry)
-
-	if entry.isHint {
-		switch entry.hintType {
-		case hintTypeWOTH:
-			tracker.AddWOTH(entry.hintText)
-		case hintTypeBarren:
-			tracker.AddBarren(entry.hintText)
-		case hintTypeSometimes:
-			tracker.AddSometimes(entry.hintText)
-		case hintTypeAlways:
-			tracker.AddAlways(entry.hintText)
-		}
-		return
-	}
-
-	if entry.isUpgrade {
-		tracker.items[entry.itemIndex].Upgrade()
-	} else {
-		tracker.items[entry.itemIndex].Downgrade()
-	}
+	tracker.undoStack = append(tracker.undoStack, cmd)
+	tracker.apply(cmd, true)
+	tracker.record(Event{Kind: EventRedo})
 }
@@ -0,0 +1,138 @@
+package tracker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUndoRedo(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		setup func(*Tracker)
+		do    func(*Tracker)
+	}{
+		{
+			name: "enable item",
+			do:   func(tracker *Tracker) { tracker.changeItem(0, true) },
+		},
+		{
+			name:  "upgrade capacity",
+			setup: func(tracker *Tracker) { tracker.changeItem(0, true) },
+			do:    func(tracker *Tracker) { tracker.changeItem(0, true) },
+		},
+		{
+			name:  "downgrade to disabled",
+			setup: func(tracker *Tracker) { tracker.changeItem(1, true) },
+			do:    func(tracker *Tracker) { tracker.changeItem(1, false) },
+		},
+		{
+			name: "set count",
+			do: func(tracker *Tracker) {
+				tracker.updateItem(2, func(item *Item) bool { return item.SetValue(37) })
+			},
+		},
+		{
+			name: "set dungeon",
+			do:   func(tracker *Tracker) { tracker.setTemple(3, tracker.getDungeonIndex("Forest")) },
+		},
+		{
+			name:  "add hint",
+			setup: func(tracker *Tracker) { tracker.addHint(0, -1, hint{Text: "Lost Woods"}) },
+			do:    func(tracker *Tracker) { tracker.addHint(0, -1, hint{Text: "Kakariko Village"}) },
+		},
+		{
+			name: "add slotted hint",
+			do: func(tracker *Tracker) {
+				tracker.addHint(2, 1, hint{Text: "Biggoron", Item: "Hookshot"})
+			},
+		},
+		{
+			name: "add small key",
+			do:   func(tracker *Tracker) { tracker.changeDungeon(0, dungeonFieldKeys, true) },
+		},
+		{
+			name: "leave Master Quest clamping keys",
+			setup: func(tracker *Tracker) {
+				tracker.changeDungeon(0, dungeonFieldMQ, true)
+				for i := 0; i < 6; i++ {
+					tracker.changeDungeon(0, dungeonFieldKeys, true)
+				}
+			},
+			do: func(tracker *Tracker) { tracker.changeDungeon(0, dungeonFieldMQ, false) },
+		},
+		{
+			name: "reset",
+			setup: func(tracker *Tracker) {
+				tracker.changeItem(0, true)
+				tracker.addHint(2, 0, hint{Text: "Skull Mask", Item: "Bow"})
+				tracker.changeDungeon(1, dungeonFieldCompleted, true)
+			},
+			do: func(tracker *Tracker) { tracker.Reset(testConfig("moved")) },
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			tracker := newTestTracker("")
+			if c.setup != nil {
+				c.setup(tracker)
+			}
+
+			before := tracker.snapshot()
+			c.do(tracker)
+			after := tracker.snapshot()
+			if reflect.DeepEqual(before, after) {
+				t.Fatal("the action did not change the state")
+			}
+
+			tracker.undo()
+			if got := tracker.snapshot(); !reflect.DeepEqual(got, before) {
+				t.Errorf("undo:\ngot  %+v\nwant %+v", got, before)
+			}
+
+			tracker.redo()
+			if got := tracker.snapshot(); !reflect.DeepEqual(got, after) {
+				t.Errorf("redo:\ngot  %+v\nwant %+v", got, after)
+			}
+		})
+	}
+}
+
+func TestUndoRedoSequence(t *testing.T) {
+	tracker := newTestTracker("")
+	initial := tracker.snapshot()
+
+	tracker.changeItem(0, true)
+	tracker.changeItem(0, true)
+	tracker.changeItem(1, true)
+	tracker.addHint(0, -1, hint{Text: "Lost Woods"})
+	tracker.addHint(2, 2, hint{Text: "Frogs", Item: "Hookshot"})
+	tracker.changeDungeon(0, dungeonFieldBossKey, true)
+	tracker.setTemple(3, tracker.getDungeonIndex("Forest"))
+	final := tracker.snapshot()
+	count := len(tracker.undoStack)
+
+	for i := 0; i < count; i++ {
+		tracker.undo()
+	}
+	if got := tracker.snapshot(); !reflect.DeepEqual(got, initial) {
+		t.Errorf("undoing everything:\ngot  %+v\nwant %+v", got, initial)
+	}
+
+	for i := 0; i < count; i++ {
+		tracker.redo()
+	}
+	if got := tracker.snapshot(); !reflect.DeepEqual(got, final) {
+		t.Errorf("redoing everything:\ngot  %+v\nwant %+v", got, final)
+	}
+}
+
+func TestUndoDropsRedoOnNewAction(t *testing.T) {
+	tracker := newTestTracker("")
+	tracker.changeItem(0, true)
+	tracker.undo()
+	tracker.changeItem(1, true)
+
+	if len(tracker.redoStack) != 0 {
+		t.Errorf("expected no command to redo, got %d", len(tracker.redoStack))
+	}
+}